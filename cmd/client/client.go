@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/johnquangdev/oauth2/repository"
+	"github.com/johnquangdev/oauth2/usecase"
+	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+)
+
+// RunCreateClient tạo OAuth client và in client_id/client_secret ra stdout (secret chỉ hiển thị một lần).
+// redirectUris, scopes, grantTypes và tokenExchangeAudiences là danh sách phân cách bằng khoảng trắng.
+func RunCreateClient(name string, redirectUris string, scopes string, grantTypes string, public bool, firstParty bool, tokenExchangeAudiences string) {
+	// Load config
+	config, err := utils.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Connect database
+	db, err := repository.ConnectPostgres(*config)
+	if err != nil {
+		log.Fatalf("Failed to connect database: %v", err)
+	}
+
+	u, err := usecase.NewUseCase(*config, repository.NewRepository(db, nil), nil)
+	if err != nil {
+		log.Fatalf("Failed to register usecase: %v", err)
+	}
+	client, err := u.Auth().OAuthServer.CreateClient(context.Background(), models.CreateClientRequest{
+		Name:                   name,
+		RedirectUris:           strings.Fields(redirectUris),
+		Scopes:                 strings.Fields(scopes),
+		GrantTypes:             strings.Fields(grantTypes),
+		IsPublic:               public,
+		FirstParty:             firstParty,
+		TokenExchangeAudiences: strings.Fields(tokenExchangeAudiences),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+
+	fmt.Printf("client_id=%s\n", client.ClientId)
+	if client.ClientSecret != "" {
+		fmt.Printf("client_secret=%s\n", client.ClientSecret)
+	}
+}
@@ -10,8 +10,10 @@ package cmd
 // @in header
 // @name Authorization
 import (
+	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/johnquangdev/oauth2/cmd/sqlmigrate"
@@ -40,6 +42,28 @@ func Run() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// lấy IP client: chỉ tin X-Forwarded-For khi request đến từ proxy đã cấu hình
+	trustedProxies, err := utils.ParseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+	if len(trustedProxies) == 0 {
+		e.IPExtractor = echo.ExtractIPDirect()
+	} else {
+		options := []echo.TrustOption{
+			echo.TrustLoopback(false),
+			echo.TrustLinkLocal(false),
+			echo.TrustPrivateNet(false),
+		}
+		for _, ipRange := range trustedProxies {
+			options = append(options, echo.TrustIPRange(ipRange))
+		}
+		e.IPExtractor = echo.ExtractIPFromXFFHeader(options...)
+	}
+
+	// rotate key: đọc lại JWT_KEYRING_DIR định kỳ
+	go config.JWTKeys.Watch(context.Background(), time.Duration(config.JWTKeyringReloadInterval)*time.Second)
+
 	// Swagger endpoint
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
@@ -71,9 +95,13 @@ func Run() {
 	}
 	middleware := myMiddleware.NewMiddleware(*config, repo)
 
+	// gửi logout token tới backchannel_logout_uri của client, có retry
+	go u.Auth().OAuthServer.WatchBackchannelLogouts(context.Background(), time.Duration(config.BackchannelLogoutInterval)*time.Second)
+
 	// register router
 	g := e.Group("/v1")
 	delivery.NewDelivery(u, g, validate, *config, middleware)
+	delivery.NewWellKnown(u, e.Group("/.well-known"), validate, *config, middleware)
 
 	// run server
 	if err := e.Start(":8080"); err != http.ErrServerClosed {
@@ -0,0 +1,178 @@
+package keyring
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/johnquangdev/oauth2/utils"
+)
+
+// loadKeyringDir đọc JWT_KEYRING_DIR từ env, không nạp key để lệnh vẫn chạy được khi keyring còn rỗng
+func loadKeyringDir() (*utils.Config, string) {
+	config, err := utils.LoadEnv()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if config.JWTKeyringDir == "" {
+		log.Fatalf("JWT_KEYRING_DIR is not set")
+	}
+	if err := os.MkdirAll(config.JWTKeyringDir, 0o700); err != nil {
+		log.Fatalf("Failed to create keyring dir: %v", err)
+	}
+	return config, config.JWTKeyringDir
+}
+
+func readKeyring(dir string) *utils.KeyringManifest {
+	manifest, err := utils.ReadKeyring(dir)
+	if err != nil {
+		log.Fatalf("Failed to read keyring: %v", err)
+	}
+	return manifest
+}
+
+func writeKeyring(dir string, manifest *utils.KeyringManifest) {
+	if err := utils.WriteKeyring(dir, manifest); err != nil {
+		log.Fatalf("Failed to write keyring: %v", err)
+	}
+}
+
+// RunGenerate tạo key mới ở trạng thái chờ: key được công bố trong JWKS ngay
+// nhưng chỉ dùng để ký sau khi promote
+func RunGenerate(alg string) {
+	_, dir := loadKeyringDir()
+	manifest := readKeyring(dir)
+
+	entry, err := utils.GenerateKeyringKey(dir, alg)
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	manifest.Keys = append(manifest.Keys, *entry)
+	writeKeyring(dir, manifest)
+
+	log.Printf("Generated %s key %s, promote it with -keyring-promote=%s", entry.Alg, entry.Kid, entry.Kid)
+}
+
+// RunPromote chọn key làm key ký từ now+activateIn.
+// Key đang ký trước đó bị retire: vẫn verify được cho tới khi token dài nhất (refresh token) ký bằng nó hết hạn.
+func RunPromote(kid string, activateIn time.Duration) {
+	config, dir := loadKeyringDir()
+	manifest := readKeyring(dir)
+
+	activatesAt := time.Now().UTC().Add(activateIn)
+	retireAfter := time.Duration(config.RefreshTokenTimeLife) * time.Hour
+	if access := time.Duration(config.AccessTokenTimeLife) * time.Minute; access > retireAfter {
+		retireAfter = access
+	}
+	expiresAt := activatesAt.Add(retireAfter)
+
+	found := false
+	for i := range manifest.Keys {
+		entry := &manifest.Keys[i]
+		if entry.Kid == kid {
+			if entry.ExpiresAt != nil && !entry.ExpiresAt.After(activatesAt) {
+				log.Fatalf("Key %s has already expired", kid)
+			}
+			entry.ActivatesAt = &activatesAt
+			entry.ExpiresAt = nil
+			found = true
+			continue
+		}
+		// key đã/sẽ ký trước đó chuyển sang chỉ verify
+		if entry.ActivatesAt != nil && (entry.ExpiresAt == nil || entry.ExpiresAt.After(expiresAt)) {
+			retiredAt := expiresAt
+			entry.ExpiresAt = &retiredAt
+		}
+	}
+	if !found {
+		log.Fatalf("Key %s not found in keyring", kid)
+	}
+	writeKeyring(dir, manifest)
+
+	log.Printf("Key %s signs new tokens from %s, previous keys expire at %s", kid, activatesAt.Format(time.RFC3339), expiresAt.Format(time.RFC3339))
+}
+
+// RunPrune xoá khỏi keyring các key đã hết hạn verify cùng file PEM của chúng
+func RunPrune() {
+	_, dir := loadKeyringDir()
+	manifest := readKeyring(dir)
+
+	now := time.Now()
+	keys := manifest.Keys[:0]
+	var pruned []utils.KeyringEntry
+	for _, entry := range manifest.Keys {
+		if entry.ExpiresAt != nil && entry.ExpiresAt.Before(now) {
+			pruned = append(pruned, entry)
+			continue
+		}
+		keys = append(keys, entry)
+	}
+	manifest.Keys = keys
+	// ghi manifest trước rồi mới xoá file để server không gặp entry trỏ tới file không tồn tại
+	writeKeyring(dir, manifest)
+	for _, entry := range pruned {
+		if err := os.Remove(filepath.Join(dir, entry.File)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove key file %s: %v", entry.File, err)
+		}
+	}
+
+	log.Printf("Pruned %d expired keys", len(pruned))
+}
+
+// RunList in trạng thái các key trong keyring
+func RunList() {
+	_, dir := loadKeyringDir()
+	manifest := readKeyring(dir)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALG\tSTATUS\tACTIVATES AT\tEXPIRES AT")
+	now := time.Now()
+	signing := signingKid(manifest, now)
+	for _, entry := range manifest.Keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.Kid, entry.Alg, status(entry, signing, now), formatTime(entry.ActivatesAt), formatTime(entry.ExpiresAt))
+	}
+	w.Flush()
+}
+
+// signingKid giống cách server chọn key: key đã activate gần nhất và chưa hết hạn
+func signingKid(manifest *utils.KeyringManifest, now time.Time) string {
+	var kid string
+	var latest time.Time
+	for _, entry := range manifest.Keys {
+		if entry.ActivatesAt == nil || entry.ActivatesAt.After(now) {
+			continue
+		}
+		if entry.ExpiresAt != nil && !entry.ExpiresAt.After(now) {
+			continue
+		}
+		if kid == "" || entry.ActivatesAt.After(latest) {
+			kid = entry.Kid
+			latest = *entry.ActivatesAt
+		}
+	}
+	return kid
+}
+
+func status(entry utils.KeyringEntry, signing string, now time.Time) string {
+	switch {
+	case entry.Kid == signing:
+		return "active"
+	case entry.ExpiresAt != nil && !entry.ExpiresAt.After(now):
+		return "expired"
+	case entry.ActivatesAt == nil:
+		return "pending"
+	case entry.ActivatesAt.After(now):
+		return "scheduled"
+	}
+	return "retired"
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -9,9 +9,22 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// NewWellKnown đăng ký các endpoint /.well-known ở gốc server (ngoài /v1)
+func NewWellKnown(u uInterface.UseCaseImpl, g *echo.Group, v *validator.Validate, cfg utils.Config, m middleware.MiddlewareCustom) {
+	handler.RegisterWellKnownHandler(u, g, v, cfg, m)
+}
+
 func NewDelivery(u uInterface.UseCaseImpl, g *echo.Group, v *validator.Validate, cfg utils.Config, m middleware.MiddlewareCustom) {
 	auth := g.Group("/auth")
 	handler.RegisterAuthSystemHandler(u, auth, v, cfg, m)
-	handler.RegisterOAuth2GoogleHandler(u, auth, v, cfg, m)
-	handler.RegisterOAAuth2GithubHandler(u, auth, v, cfg, m)
+	handler.RegisterIdentityHandler(u, auth, v, cfg, m)
+	handler.RegisterSessionHandler(u, auth, v, cfg, m)
+	handler.RegisterAuthorizedAppHandler(u, auth, v, cfg, m)
+	handler.RegisterOAuth2Handler(u, auth, v, cfg, m)
+
+	oauth := g.Group("/oauth")
+	handler.RegisterOAuthServerHandler(u, oauth, v, cfg, m)
+
+	admin := g.Group("/admin", m.JWTAuthMiddleware(), m.RequireAdmin())
+	handler.RegisterAdminHandler(u, admin, v, cfg, m)
 }
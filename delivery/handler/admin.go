@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	dModels "github.com/johnquangdev/oauth2/delivery/models"
+	"github.com/johnquangdev/oauth2/middleware"
+	"github.com/johnquangdev/oauth2/usecase/interfaces"
+	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type adminHandler struct {
+	validate   *validator.Validate
+	useCase    interfaces.UseCaseImpl
+	config     utils.Config
+	middleware middleware.MiddlewareCustom
+}
+
+// RegisterAdminHandler đăng ký các API quản trị, group g đã được bảo vệ bởi RequireAdmin
+func RegisterAdminHandler(u interfaces.UseCaseImpl, g *echo.Group, v *validator.Validate, cfg utils.Config, m middleware.MiddlewareCustom) {
+	r := &adminHandler{
+		useCase:    u,
+		validate:   v,
+		config:     cfg,
+		middleware: m,
+	}
+	g.POST("/users/:id/block", r.handlerBlockUser)
+	g.POST("/users/:id/unblock", r.handlerUnblockUser)
+	g.GET("/clients", r.handlerListClients)
+	g.POST("/clients", r.handlerCreateClient)
+	g.GET("/clients/:id", r.handlerGetClient)
+	g.POST("/clients/:id/rotate-secret", r.handlerRotateClientSecret)
+	g.POST("/clients/:id/disable", r.handlerDisableClient)
+	g.POST("/clients/:id/enable", r.handlerEnableClient)
+}
+
+// @Summary Block user
+// @Description Khoá tài khoản và thu hồi mọi session của user (chỉ admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "user id"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /v1/admin/users/{id}/block [post]
+func (h *adminHandler) handlerBlockUser(c echo.Context) error {
+	return h.updateUserStatus(c, h.useCase.Auth().SystemAuth.BlockUser, "user blocked")
+}
+
+// @Summary Unblock user
+// @Description Mở khoá tài khoản đã bị block (chỉ admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "user id"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /v1/admin/users/{id}/unblock [post]
+func (h *adminHandler) handlerUnblockUser(c echo.Context) error {
+	return h.updateUserStatus(c, h.useCase.Auth().SystemAuth.UnblockUser, "user unblocked")
+}
+
+func (h *adminHandler) updateUserStatus(c echo.Context, update func(ctx context.Context, userId uuid.UUID) error, message string) error {
+	userId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": "invalid user id",
+		})
+	}
+	if err := update(c.Request().Context(), userId); err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"status":  http.StatusNotFound,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":  http.StatusOK,
+		"message": message,
+	})
+}
+
+// @Summary List OAuth clients
+// @Description Danh sách OAuth client đã đăng ký (chỉ admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /v1/admin/clients [get]
+func (h *adminHandler) handlerListClients(c echo.Context) error {
+	clients, err := h.useCase.Auth().OAuthServer.ListClients(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":  http.StatusOK,
+		"clients": clients,
+	})
+}
+
+// @Summary Create OAuth client
+// @Description Đăng ký OAuth client, client_secret chỉ trả về một lần (chỉ admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body dModels.CreateClient true "client metadata"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /v1/admin/clients [post]
+func (h *adminHandler) handlerCreateClient(c echo.Context) error {
+	var req dModels.CreateClient
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+	}
+	if err := h.validate.Struct(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+	}
+	client, err := h.useCase.Auth().OAuthServer.CreateClient(c.Request().Context(), models.CreateClientRequest{
+		Name:                               req.Name,
+		RedirectUris:                       req.RedirectUris,
+		Scopes:                             req.Scopes,
+		GrantTypes:                         req.GrantTypes,
+		IsPublic:                           req.IsPublic,
+		FirstParty:                         req.FirstParty,
+		Jwks:                               req.Jwks,
+		RequirePushedAuthorizationRequests: req.RequirePushedAuthorizationRequests,
+		RequireSignedRequestObject:         req.RequireSignedRequestObject,
+		PostLogoutRedirectUris:             req.PostLogoutRedirectUris,
+		BackchannelLogoutUri:               req.BackchannelLogoutUri,
+		FrontchannelLogoutUri:              req.FrontchannelLogoutUri,
+		TokenExchangeAudiences:             req.TokenExchangeAudiences,
+		AccessTokenTimeLife:                req.AccessTokenTimeLife,
+		RefreshTokenTimeLife:               req.RefreshTokenTimeLife,
+	})
+	if err != nil {
+		return clientAdminError(c, err)
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"status": http.StatusCreated,
+		"client": client,
+	})
+}
+
+// @Summary Get OAuth client
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "client id (uuid)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /v1/admin/clients/{id} [get]
+func (h *adminHandler) handlerGetClient(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": "invalid client id",
+		})
+	}
+	client, err := h.useCase.Auth().OAuthServer.GetClient(c.Request().Context(), id)
+	if err != nil {
+		return clientAdminError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": http.StatusOK,
+		"client": client,
+	})
+}
+
+// @Summary Rotate OAuth client secret
+// @Description Cấp client_secret mới, secret cũ hết hiệu lực ngay (chỉ admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "client id (uuid)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /v1/admin/clients/{id}/rotate-secret [post]
+func (h *adminHandler) handlerRotateClientSecret(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": "invalid client id",
+		})
+	}
+	client, err := h.useCase.Auth().OAuthServer.RotateClientSecret(c.Request().Context(), id)
+	if err != nil {
+		return clientAdminError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": http.StatusOK,
+		"client": client,
+	})
+}
+
+// @Summary Disable OAuth client
+// @Description Chặn client xác thực và thu hồi mọi session đã cấp cho client (chỉ admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "client id (uuid)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /v1/admin/clients/{id}/disable [post]
+func (h *adminHandler) handlerDisableClient(c echo.Context) error {
+	return h.updateClient(c, h.useCase.Auth().OAuthServer.DisableClient, "client disabled")
+}
+
+// @Summary Enable OAuth client
+// @Description Kích hoạt lại client đã bị vô hiệu hoá (chỉ admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "client id (uuid)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /v1/admin/clients/{id}/enable [post]
+func (h *adminHandler) handlerEnableClient(c echo.Context) error {
+	return h.updateClient(c, h.useCase.Auth().OAuthServer.EnableClient, "client enabled")
+}
+
+func (h *adminHandler) updateClient(c echo.Context, update func(ctx context.Context, id uuid.UUID) error, message string) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": "invalid client id",
+		})
+	}
+	if err := update(c.Request().Context(), id); err != nil {
+		return clientAdminError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":  http.StatusOK,
+		"message": message,
+	})
+}
+
+func clientAdminError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, models.ErrClientNotFound):
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"status":  http.StatusNotFound,
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrInvalidClientMetadata), errors.Is(err, models.ErrInvalidClientRedirect),
+		errors.Is(err, models.ErrPublicClient):
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+		"status": http.StatusInternalServerError,
+		"detail": err.Error(),
+	})
+}
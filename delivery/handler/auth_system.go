@@ -2,15 +2,17 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/johnquangdev/oauth2/delivery/models"
 	"github.com/johnquangdev/oauth2/middleware"
 	"github.com/johnquangdev/oauth2/usecase/interfaces"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
 	"github.com/johnquangdev/oauth2/utils"
 	"github.com/labstack/echo/v4"
 )
@@ -30,6 +32,7 @@ func RegisterAuthSystemHandler(u interfaces.UseCaseImpl, g *echo.Group, v *valid
 		middleware: m,
 	}
 	g.POST("/logout", r.handlerLogout)
+	g.POST("/refresh", r.handlerRefresh)
 	g.GET("/profile", r.handleGetProfile, m.JWTAuthMiddleware())
 }
 
@@ -63,11 +66,13 @@ func (h *AuthSystemHandler) handleGetProfile(c echo.Context) error {
 }
 
 // @Summary Logout người dùng
-// @Description Thoát phiên làm việc của người dùng
+// @Description Thu hồi session của refresh token (refresh token và access token còn hạn), không ảnh hưởng session khác
 // @Tags Auth
 // @Accept json
 // @Produce json
+// @Param body body models.Logout true "refresh token"
 // @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{} "Refresh token không hợp lệ"
 // @Router /v1/auth/logout [post]
 func (h *AuthSystemHandler) handlerLogout(c echo.Context) error {
 	var logout models.Logout
@@ -85,33 +90,87 @@ func (h *AuthSystemHandler) handlerLogout(c echo.Context) error {
 			"message": err.Error(),
 		})
 	}
-	// verify refresh token
-	tokenVerify, err := utils.VerifyToken(logout.RefreshToken, h.config.SecretKey)
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"status":  http.StatusBadRequest,
-			"message": err.Error(),
-		})
+	// access token gửi kèm (nếu có) cũng bị thu hồi ngay
+	if accessToken, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer "); ok {
+		if err := h.useCase.Auth().SystemAuth.RevokeToken(c.Request().Context(), accessToken); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"status": http.StatusInternalServerError,
+				"detail": err.Error(),
+			})
+		}
 	}
-	userId := tokenVerify.Id
 	// call usecase logout
-	if err := h.useCase.Auth().GoogleOauth2.Logout(context.Background(), tokenVerify.Id); err != nil {
+	if err := h.useCase.Auth().SystemAuth.Logout(c.Request().Context(), logout.RefreshToken); err != nil {
+		if errors.Is(err, uModels.ErrInvalidRefreshToken) {
+			return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+				"status":  http.StatusUnauthorized,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":  http.StatusOK,
+		"message": "logout ok",
+	})
+}
+
+// @Summary Refresh token
+// @Description Đổi refresh token lấy cặp access/refresh token mới (refresh token cũ bị rotate)
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body models.RefreshToken true "refresh token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{} "Refresh token không hợp lệ hoặc hết hạn"
+// @Failure 403 {object} map[string]interface{} "Session đã bị revoke/block hoặc refresh token bị dùng lại"
+// @Router /v1/auth/refresh [post]
+func (h *AuthSystemHandler) handlerRefresh(c echo.Context) error {
+	var req models.RefreshToken
+	// bind refreshToken
+	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"status":  http.StatusBadRequest,
 			"message": err.Error(),
 		})
 	}
-
-	// add user backlist
-	if err := h.useCase.Auth().SystemAuth.AddBackList(userId, logout.RefreshToken, time.Until((tokenVerify.ExpiresAt.Time))); err != nil {
+	// check validate
+	if err := h.validate.Struct(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"status":  http.StatusBadRequest,
 			"message": err.Error(),
 		})
 	}
-
+	// call usecase refresh
+	token, err := h.useCase.Auth().SystemAuth.Refresh(c.Request().Context(), req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, uModels.ErrInvalidRefreshToken):
+			return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+				"status":  http.StatusUnauthorized,
+				"message": err.Error(),
+			})
+		case errors.Is(err, uModels.ErrSessionRevoked),
+			errors.Is(err, uModels.ErrSessionBlocked),
+			errors.Is(err, uModels.ErrRefreshTokenReused),
+			errors.Is(err, uModels.ErrUserNotActive):
+			return c.JSON(http.StatusForbidden, map[string]interface{}{
+				"status":  http.StatusForbidden,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"status":  http.StatusOK,
-		"message": "logout ok",
+		"status": http.StatusOK,
+		"token":  token,
 	})
 }
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/johnquangdev/oauth2/middleware"
+	"github.com/johnquangdev/oauth2/usecase/interfaces"
+	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type authorizedAppHandler struct {
+	validate   *validator.Validate
+	useCase    interfaces.UseCaseImpl
+	config     utils.Config
+	middleware middleware.MiddlewareCustom
+}
+
+func RegisterAuthorizedAppHandler(u interfaces.UseCaseImpl, g *echo.Group, v *validator.Validate, cfg utils.Config, m middleware.MiddlewareCustom) {
+	r := &authorizedAppHandler{
+		useCase:    u,
+		validate:   v,
+		config:     cfg,
+		middleware: m,
+	}
+	apps := g.Group("/authorized-apps", m.JWTAuthMiddleware())
+
+	apps.GET("", r.handlerListAuthorizedApps)
+	apps.DELETE("/:client_id", r.handlerRevokeAuthorizedApp)
+}
+
+// @Summary Danh sách app đã cấp quyền
+// @Description Trả về các app bên thứ ba user đã đồng ý cấp quyền và các scope đã cấp
+// @Tags Session
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /v1/auth/authorized-apps [get]
+func (h *authorizedAppHandler) handlerListAuthorizedApps(c echo.Context) error {
+	userId, ok := c.Get("claims").(uuid.UUID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "userId not found in context")
+	}
+	apps, err := h.useCase.Auth().SystemAuth.ListAuthorizedApps(c.Request().Context(), userId)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": http.StatusOK,
+		"apps":   apps,
+	})
+}
+
+// @Summary Gỡ quyền của một app
+// @Description Xoá quyền đã cấp và revoke mọi refresh token, access token user đã cấp cho app
+// @Tags Session
+// @Security BearerAuth
+// @Produce json
+// @Param client_id path string true "client id của app"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /v1/auth/authorized-apps/{client_id} [delete]
+func (h *authorizedAppHandler) handlerRevokeAuthorizedApp(c echo.Context) error {
+	userId, ok := c.Get("claims").(uuid.UUID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "userId not found in context")
+	}
+	if err := h.useCase.Auth().SystemAuth.RevokeAuthorizedApp(c.Request().Context(), userId, c.Param("client_id")); err != nil {
+		if errors.Is(err, models.ErrAuthorizedAppNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"status":  http.StatusNotFound,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":  http.StatusOK,
+		"message": "app access revoked",
+	})
+}
@@ -0,0 +1,14 @@
+package handler
+
+import (
+	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/labstack/echo/v4"
+)
+
+// clientInfo lấy IP (qua IPExtractor theo TRUSTED_PROXIES) và user agent của request
+func clientInfo(c echo.Context) models.ClientInfo {
+	return models.ClientInfo{
+		IPAddress: c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	}
+}
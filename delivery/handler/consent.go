@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/labstack/echo/v4"
+)
+
+// consentPage là màn hình user đồng ý cấp scope cho client bên thứ ba trong luồng
+// /v1/oauth/authorize. Form gửi kèm csrf_token gắn với phiên đã xem trang, ngoài
+// SameSite của cookie (xem setSSOSessionCookie)
+var consentPage = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Allow {{.ClientName}}?</title></head>
+<body>
+<h1>{{.ClientName}} wants to access your account</h1>
+{{if .Scopes}}<p>This will allow {{.ClientName}} to:</p>
+<ul>
+{{range .Scopes}}<li>{{.Description}}</li>
+{{end}}</ul>{{end}}
+<form method="post" action="/v1/oauth/consent">
+<input type="hidden" name="request_id" value="{{.RequestId}}">
+<input type="hidden" name="csrf_token" value="{{.CsrfToken}}">
+<button type="submit" name="action" value="approve">Allow</button>
+<button type="submit" name="action" value="deny">Deny</button>
+</form>
+</body>
+</html>
+`))
+
+// @Summary Màn hình đồng ý cấp quyền
+// @Description Liệt kê scope client bên thứ ba đang xin. Trình duyệt chưa đăng nhập được đưa tới trang chọn provider.
+// @Tags OAuth Server
+// @Produce html
+// @Param request_id query string true "id authorization request"
+// @Success 200
+// @Success 302
+// @Failure 400 {object} map[string]interface{}
+// @Router /v1/oauth/consent [get]
+func (h *oAuthServerHandler) handlerConsentPage(c echo.Context) error {
+	info, err := h.useCase.Auth().OAuthServer.GetConsentRequest(c.Request().Context(), getSSOSessionCookie(c), c.QueryParam("request_id"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidAuthzRequest) {
+			return oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	if info.LoginURL != "" {
+		return c.Redirect(http.StatusFound, info.LoginURL)
+	}
+	var page bytes.Buffer
+	if err := consentPage.Execute(&page, info); err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	// không cho site khác nhúng màn hình đồng ý vào iframe (clickjacking)
+	c.Response().Header().Set("X-Frame-Options", "DENY")
+	c.Response().Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	return c.HTMLBlob(http.StatusOK, page.Bytes())
+}
+
+// @Summary Đồng ý hoặc từ chối cấp quyền
+// @Description Đồng ý thì lưu scope đã cấp và redirect về client kèm code, từ chối thì redirect kèm error=access_denied
+// @Tags OAuth Server
+// @Accept x-www-form-urlencoded
+// @Param request_id formData string true "id authorization request"
+// @Param csrf_token formData string true "token trong form của màn hình đồng ý"
+// @Param action formData string true "approve hoặc deny"
+// @Success 302
+// @Failure 400 {object} map[string]interface{}
+// @Router /v1/oauth/consent [post]
+func (h *oAuthServerHandler) handlerConsent(c echo.Context) error {
+	requestId := c.FormValue("request_id")
+	redirect, err := h.useCase.Auth().OAuthServer.Consent(c.Request().Context(), getSSOSessionCookie(c), requestId, c.FormValue("csrf_token"), c.FormValue("action") == "approve", clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrLoginRequired):
+			// quay lại màn hình đồng ý, trang này sẽ đưa user đi đăng nhập
+			return c.Redirect(http.StatusFound, "/v1/oauth/consent?"+url.Values{"request_id": {requestId}}.Encode())
+		case errors.Is(err, models.ErrInvalidAuthzRequest):
+			return oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	return c.Redirect(http.StatusFound, redirect)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/johnquangdev/oauth2/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const stateBindingCookie = "oauth2_state_binding"
+
+// setStateBindingCookie gắn OAuth state với trình duyệt đang login.
+// Dùng lại cookie đang có để nhiều tab login song song không ghi đè lẫn nhau.
+func setStateBindingCookie(c echo.Context, ttl time.Duration) (string, error) {
+	binding := getStateBindingCookie(c)
+	if binding == "" {
+		value, err := utils.GenerateRandomString(32)
+		if err != nil {
+			return "", err
+		}
+		binding = value
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     stateBindingCookie,
+		Value:    binding,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return binding, nil
+}
+
+func getStateBindingCookie(c echo.Context) string {
+	cookie, err := c.Cookie(stateBindingCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+const ssoSessionCookie = "oauth2_sso"
+
+// setSSOSessionCookie ghi phiên đăng nhập của trình duyệt ở authorization server,
+// chỉ gửi kèm các request tới /v1/oauth. SameSite=Lax nên trình duyệt không gửi cookie với
+// form POST từ site khác: các form consent, device và end_session dựa vào đó để chống CSRF.
+func setSSOSessionCookie(c echo.Context, id string, ttl time.Duration) {
+	c.SetCookie(&http.Cookie{
+		Name:     ssoSessionCookie,
+		Value:    id,
+		Path:     "/v1/oauth",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func getSSOSessionCookie(c echo.Context) string {
+	cookie, err := c.Cookie(ssoSessionCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// clearSSOSessionCookie xoá cookie SSO khi trình duyệt đăng xuất ở /v1/oauth/end_session
+func clearSSOSessionCookie(c echo.Context) {
+	c.SetCookie(&http.Cookie{
+		Name:     ssoSessionCookie,
+		Value:    "",
+		Path:     "/v1/oauth",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
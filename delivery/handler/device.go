@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/labstack/echo/v4"
+)
+
+// devicePage là trang user nhập và duyệt user_code của device authorization grant
+// (chống CSRF: xem setSSOSessionCookie)
+var devicePage = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Connect a device</title></head>
+<body>
+<h1>Connect a device</h1>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{if .Verification}}
+<p><strong>{{.Verification.ClientName}}</strong> wants to access your account{{if .Verification.Scopes}} ({{range $i, $s := .Verification.Scopes}}{{if $i}}, {{end}}{{$s}}{{end}}){{end}}.</p>
+<p>Only continue if this code is shown on your device: <strong>{{.Verification.UserCode}}</strong></p>
+<form method="post" action="/v1/oauth/device">
+<input type="hidden" name="user_code" value="{{.Verification.UserCode}}">
+<button type="submit" name="action" value="approve">Allow</button>
+<button type="submit" name="action" value="deny">Deny</button>
+</form>
+{{else if not .Done}}
+<form method="get" action="/v1/oauth/device">
+<label>Code shown on your device <input name="user_code" autocomplete="off" autofocus></label>
+<button type="submit">Continue</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type devicePageData struct {
+	Message      string
+	Verification *models.DeviceVerification
+	Done         bool
+}
+
+// @Summary Device authorization endpoint (RFC 8628)
+// @Description Thiết bị không có trình duyệt (CLI, TV) lấy device_code và user_code, rồi poll token endpoint với grant urn:ietf:params:oauth:grant-type:device_code
+// @Tags OAuth Server
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param client_id formData string false "client id (client public)"
+// @Param scope formData string false "scope"
+// @Success 200 {object} models.DeviceAuthorizationResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /v1/oauth/device_authorization [post]
+func (h *oAuthServerHandler) handlerDeviceAuthorization(c echo.Context) error {
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		return oauthClientError(c, err)
+	}
+	result, err := h.useCase.Auth().OAuthServer.DeviceAuthorization(c.Request().Context(), client, c.FormValue("scope"))
+	if err != nil {
+		var oauthErr *models.OAuthError
+		if errors.As(err, &oauthErr) {
+			return oauthError(c, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, result)
+}
+
+// @Summary Trang duyệt device code
+// @Description User nhập user_code, đăng nhập nếu cần rồi duyệt hoặc từ chối thiết bị
+// @Tags OAuth Server
+// @Produce html
+// @Param user_code query string false "user code hiển thị trên thiết bị"
+// @Success 200
+// @Success 302
+// @Router /v1/oauth/device [get]
+func (h *oAuthServerHandler) handlerDevicePage(c echo.Context) error {
+	userCode := c.QueryParam("user_code")
+	if userCode == "" {
+		return h.renderDevicePage(c, http.StatusOK, devicePageData{})
+	}
+	verification, err := h.useCase.Auth().OAuthServer.GetDeviceVerification(c.Request().Context(), getSSOSessionCookie(c), userCode)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidUserCode) {
+			return h.renderDevicePage(c, http.StatusBadRequest, devicePageData{Message: "This code is invalid or has expired. Check your device and try again."})
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	if verification.LoginURL != "" {
+		return c.Redirect(http.StatusFound, verification.LoginURL)
+	}
+	return h.renderDevicePage(c, http.StatusOK, devicePageData{Verification: verification})
+}
+
+// @Summary Duyệt hoặc từ chối device code
+// @Tags OAuth Server
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param user_code formData string true "user code"
+// @Param action formData string true "approve hoặc deny"
+// @Success 200
+// @Failure 400
+// @Router /v1/oauth/device [post]
+func (h *oAuthServerHandler) handlerDeviceApprove(c echo.Context) error {
+	userCode := c.FormValue("user_code")
+	approve := c.FormValue("action") == "approve"
+	err := h.useCase.Auth().OAuthServer.ApproveDevice(c.Request().Context(), getSSOSessionCookie(c), userCode, approve, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrLoginRequired):
+			// quay lại trang duyệt, trang này sẽ đưa user đi đăng nhập
+			return c.Redirect(http.StatusFound, "/v1/oauth/device?"+url.Values{"user_code": {userCode}}.Encode())
+		case errors.Is(err, models.ErrInvalidUserCode):
+			return h.renderDevicePage(c, http.StatusBadRequest, devicePageData{Message: "This code is invalid or has expired. Check your device and try again."})
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	message := "Device connected. You can return to your device."
+	if !approve {
+		message = "Request denied. The device was not connected."
+	}
+	return h.renderDevicePage(c, http.StatusOK, devicePageData{Message: message, Done: true})
+}
+
+func (h *oAuthServerHandler) renderDevicePage(c echo.Context, status int, data devicePageData) error {
+	var page bytes.Buffer
+	if err := devicePage.Execute(&page, data); err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	// trang nhập user_code là đích quen thuộc của phishing/clickjacking, không cho nhúng vào iframe
+	c.Response().Header().Set("X-Frame-Options", "DENY")
+	c.Response().Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	return c.HTMLBlob(status, page.Bytes())
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	dModels "github.com/johnquangdev/oauth2/delivery/models"
+	"github.com/johnquangdev/oauth2/middleware"
+	"github.com/johnquangdev/oauth2/usecase/interfaces"
+	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type identityHandler struct {
+	validate   *validator.Validate
+	useCase    interfaces.UseCaseImpl
+	config     utils.Config
+	middleware middleware.MiddlewareCustom
+}
+
+func RegisterIdentityHandler(u interfaces.UseCaseImpl, g *echo.Group, v *validator.Validate, cfg utils.Config, m middleware.MiddlewareCustom) {
+	r := &identityHandler{
+		useCase:    u,
+		validate:   v,
+		config:     cfg,
+		middleware: m,
+	}
+	identities := g.Group("/identities", m.JWTAuthMiddleware())
+
+	identities.GET("", r.handlerListIdentities)
+	identities.POST("/:provider/link", r.handlerLinkIdentity)
+	identities.POST("/confirm", r.handlerConfirmLink)
+	identities.DELETE("/:id", r.handlerUnlinkIdentity)
+}
+
+// @Summary Danh sách provider đã liên kết
+// @Description Trả về các identity (Google, GitHub, OIDC...) đã liên kết với user hiện tại
+// @Tags Identity
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /v1/auth/identities [get]
+func (h *identityHandler) handlerListIdentities(c echo.Context) error {
+	userId, ok := c.Get("claims").(uuid.UUID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "userId not found in context")
+	}
+	identities, err := h.useCase.Auth().SystemAuth.ListIdentities(c.Request().Context(), userId)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":     http.StatusOK,
+		"identities": identities,
+	})
+}
+
+// @Summary Liên kết thêm provider
+// @Description Trả về URL login của provider; callback sẽ liên kết identity vào user hiện tại thay vì đăng nhập
+// @Tags Identity
+// @Security BearerAuth
+// @Produce json
+// @Param provider path string true "tên provider"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /v1/auth/identities/{provider}/link [post]
+func (h *identityHandler) handlerLinkIdentity(c echo.Context) error {
+	userId, ok := c.Get("claims").(uuid.UUID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "userId not found in context")
+	}
+	// cookie binding để callback chỉ chấp nhận state từ đúng trình duyệt này
+	binding, err := setStateBindingCookie(c, time.Duration(h.config.OAuthStateTimeLife)*time.Minute)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+	}
+	authURL, err := h.useCase.Auth().OAuth2.GetLinkURL(c.Request().Context(), c.Param("provider"), binding, userId)
+	if err != nil {
+		if errors.Is(err, models.ErrProviderNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"status":  http.StatusNotFound,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":   http.StatusOK,
+		"auth_url": authURL,
+	})
+}
+
+// @Summary Xác nhận liên kết provider
+// @Description Liên kết identity đang chờ (ACCOUNT_LINKING_POLICY=confirm) vào user hiện tại
+// @Tags Identity
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body dModels.ConfirmLink true "link token nhận được ở callback"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /v1/auth/identities/confirm [post]
+func (h *identityHandler) handlerConfirmLink(c echo.Context) error {
+	userId, ok := c.Get("claims").(uuid.UUID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "userId not found in context")
+	}
+	var req dModels.ConfirmLink
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+	}
+	if err := h.validate.Struct(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+	}
+	identity, err := h.useCase.Auth().OAuth2.ConfirmLink(c.Request().Context(), userId, req.LinkToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidLinkToken):
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"status":  http.StatusBadRequest,
+				"message": err.Error(),
+			})
+		case errors.Is(err, models.ErrIdentityLinked):
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"status":  http.StatusConflict,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":          http.StatusOK,
+		"linked_identity": identity,
+	})
+}
+
+// @Summary Gỡ liên kết provider
+// @Description Gỡ một identity khỏi user hiện tại, không cho gỡ identity cuối cùng
+// @Tags Identity
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "identity id"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "identity cuối cùng"
+// @Router /v1/auth/identities/{id} [delete]
+func (h *identityHandler) handlerUnlinkIdentity(c echo.Context) error {
+	userId, ok := c.Get("claims").(uuid.UUID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "userId not found in context")
+	}
+	identityId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": "invalid identity id",
+		})
+	}
+	if err := h.useCase.Auth().SystemAuth.UnlinkIdentity(c.Request().Context(), userId, identityId); err != nil {
+		switch {
+		case errors.Is(err, models.ErrIdentityNotFound):
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"status":  http.StatusNotFound,
+				"message": err.Error(),
+			})
+		case errors.Is(err, models.ErrLastIdentity):
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"status":  http.StatusConflict,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":  http.StatusOK,
+		"message": "identity unlinked",
+	})
+}
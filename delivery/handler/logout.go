@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/labstack/echo/v4"
+)
+
+// endSessionConfirmPage hỏi user trước khi đăng xuất khi request không có id_token_hint
+// của đúng user đang đăng nhập (chống CSRF: xem setSSOSessionCookie)
+var endSessionConfirmPage = template.Must(template.New("end_session_confirm").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign out?</title></head>
+<body>
+<h1>Sign out?</h1>
+<p>{{if .ClientName}}{{.ClientName}} is asking to sign you out. {{end}}You will be signed out of every app you signed in to with this account in this browser.</p>
+<form method="post" action="/v1/oauth/end_session">
+<input type="hidden" name="id_token_hint" value="{{.IdTokenHint}}">
+<input type="hidden" name="client_id" value="{{.ClientId}}">
+<input type="hidden" name="post_logout_redirect_uri" value="{{.PostLogoutRedirectUri}}">
+<input type="hidden" name="state" value="{{.State}}">
+<button type="submit" name="action" value="logout">Sign out</button>
+</form>
+</body>
+</html>
+`))
+
+// loggedOutPage nạp frontchannel_logout_uri của các client trong iframe ẩn rồi mới chuyển
+// về post_logout_redirect_uri, để các client kịp xoá phiên của chúng trong trình duyệt
+var loggedOutPage = template.Must(template.New("logged_out").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Signed out</title>
+{{if .RedirectURL}}<meta http-equiv="refresh" content="{{if .FrontchannelLogoutUris}}2{{else}}0{{end}};url={{.RedirectURL}}">{{end}}
+</head>
+<body>
+<h1>You have been signed out</h1>
+{{if .RedirectURL}}<p><a href="{{.RedirectURL}}">Continue</a></p>{{end}}
+{{range .FrontchannelLogoutUris}}<iframe src="{{.}}" style="display:none" width="0" height="0"></iframe>
+{{end}}</body>
+</html>
+`))
+
+// @Summary Đăng xuất (OIDC RP-Initiated Logout)
+// @Description Kết thúc phiên đăng nhập của trình duyệt và mọi session client đã nhận từ phiên đó. Client có backchannel_logout_uri nhận logout token, client có frontchannel_logout_uri được nạp trong iframe. post_logout_redirect_uri phải khớp chính xác URI đã đăng ký.
+// @Tags OAuth Server
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param id_token_hint query string false "ID token đã cấp cho client, được nhận cả khi đã hết hạn"
+// @Param client_id query string false "client_id, bắt buộc khi có post_logout_redirect_uri mà không có id_token_hint"
+// @Param post_logout_redirect_uri query string false "URI redirect sau khi đăng xuất"
+// @Param state query string false "trả lại nguyên vẹn cho client"
+// @Success 200
+// @Success 302
+// @Failure 400 {object} map[string]interface{}
+// @Router /v1/oauth/end_session [get]
+func (h *oAuthServerHandler) handlerEndSession(c echo.Context) error {
+	req := models.EndSessionRequest{
+		IdTokenHint:           c.FormValue("id_token_hint"),
+		ClientId:              c.FormValue("client_id"),
+		PostLogoutRedirectUri: c.FormValue("post_logout_redirect_uri"),
+		State:                 c.FormValue("state"),
+		SSOSessionId:          getSSOSessionCookie(c),
+		Confirmed:             c.Request().Method == http.MethodPost && c.FormValue("action") == "logout",
+	}
+	result, err := h.useCase.Auth().OAuthServer.EndSession(c.Request().Context(), req)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidLogoutRequest) {
+			return oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("X-Frame-Options", "DENY")
+	c.Response().Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	var page bytes.Buffer
+	if result.ConfirmationRequired {
+		err = endSessionConfirmPage.Execute(&page, struct {
+			models.EndSessionRequest
+			ClientName string
+		}{req, result.ClientName})
+		if err != nil {
+			return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+		}
+		return c.HTMLBlob(http.StatusOK, page.Bytes())
+	}
+	clearSSOSessionCookie(c)
+	if len(result.FrontchannelLogoutUris) == 0 && result.RedirectURL != "" {
+		return c.Redirect(http.StatusFound, result.RedirectURL)
+	}
+	if err := loggedOutPage.Execute(&page, result); err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	return c.HTMLBlob(http.StatusOK, page.Bytes())
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/johnquangdev/oauth2/middleware"
+	"github.com/johnquangdev/oauth2/usecase/interfaces"
+	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type oAuth2Handler struct {
+	validate   *validator.Validate
+	useCase    interfaces.UseCaseImpl
+	config     utils.Config
+	middleware middleware.MiddlewareCustom
+}
+
+func RegisterOAuth2Handler(u interfaces.UseCaseImpl, g *echo.Group, v *validator.Validate, cfg utils.Config, m middleware.MiddlewareCustom) {
+	r := oAuth2Handler{
+		useCase:    u,
+		validate:   v,
+		config:     cfg,
+		middleware: m,
+	}
+	// provider là tên trong registry: google, github hoặc OIDC provider đã cấu hình
+	g.GET("/:provider/login", r.handlerLogin)
+	g.GET("/:provider/callback", r.handlerCallback)
+}
+
+// @Summary OAuth2 Login
+// @Description Redirect người dùng đến trang đăng nhập của provider (google, github hoặc OIDC provider đã cấu hình)
+// @Tags OAuth2
+// @Produce json
+// @Param provider path string true "tên provider"
+// @Param authorization_request query string false "id authorization request của /v1/oauth/authorize"
+// @Success 307
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /v1/auth/{provider}/login [get]
+func (h *oAuth2Handler) handlerLogin(c echo.Context) error {
+	// cookie binding để callback chỉ chấp nhận state từ đúng trình duyệt này
+	binding, err := setStateBindingCookie(c, time.Duration(h.config.OAuthStateTimeLife)*time.Minute)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+	}
+	loginURL, err := h.useCase.Auth().OAuth2.GetAuthURL(c.Request().Context(), c.Param("provider"), binding, c.QueryParam("authorization_request"))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrProviderNotFound):
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"status":  http.StatusNotFound,
+				"message": err.Error(),
+			})
+		case errors.Is(err, models.ErrInvalidAuthzRequest):
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"status":  http.StatusBadRequest,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+	}
+	return c.Redirect(http.StatusTemporaryRedirect, loginURL)
+}
+
+// @Summary OAuth2 Callback
+// @Description Callback and create user or allow user login
+// @Tags OAuth2
+// @Produce json
+// @Param provider path string true "tên provider"
+// @Param code query string true "authorization code"
+// @Param state query string true "state đã tạo ở bước login"
+// @Success 200 {object} map[string]interface{}
+// @Success 202 {object} map[string]interface{} "cần xác nhận liên kết vào tài khoản có sẵn"
+// @Failure 400 {object} map[string]interface{}
+// @Router /v1/auth/{provider}/callback [get]
+func (h *oAuth2Handler) handlerCallback(c echo.Context) error {
+	ctx := c.Request().Context()
+	// provider trả lỗi (user từ chối, client sai cấu hình...)
+	if errParam := c.QueryParam("error"); errParam != "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": errParam + ": " + c.QueryParam("error_description"),
+		})
+	}
+	code := c.QueryParam("code")
+	if code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": "Missing authorization code",
+		})
+	}
+	state := c.QueryParam("state")
+	if state == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": "Missing state",
+		})
+	}
+	result, err := h.useCase.Auth().OAuth2.Login(ctx, c.Param("provider"), models.ExchangeTokenRequest{
+		Code:    code,
+		State:   state,
+		Binding: getStateBindingCookie(c),
+		Client:  clientInfo(c),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrProviderNotFound):
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"status":  http.StatusNotFound,
+				"message": err.Error(),
+			})
+		case errors.Is(err, models.ErrMissingState), errors.Is(err, models.ErrInvalidState),
+			errors.Is(err, models.ErrInvalidAuthzRequest):
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"status":  http.StatusBadRequest,
+				"message": err.Error(),
+			})
+		case errors.Is(err, models.ErrIdentityLinked):
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"status":  http.StatusConflict,
+				"message": err.Error(),
+			})
+		case errors.Is(err, models.ErrUserNotActive):
+			return c.JSON(http.StatusForbidden, map[string]interface{}{
+				"status":  http.StatusForbidden,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+	// email trùng tài khoản có sẵn: user cần đăng nhập tài khoản đó rồi xác nhận liên kết
+	if result.PendingLink != nil {
+		return c.JSON(http.StatusAccepted, map[string]interface{}{
+			"status":       http.StatusAccepted,
+			"message":      "an account with this email already exists, sign in to it and confirm the link",
+			"pending_link": result.PendingLink,
+		})
+	}
+	// login là bước xác thực của /v1/oauth/authorize: trả code về client
+	if result.AuthorizationRedirect != "" {
+		setSSOSessionCookie(c, result.SSOSessionId, time.Duration(h.config.SSOSessionTimeLife)*time.Hour)
+		return c.Redirect(http.StatusFound, result.AuthorizationRedirect)
+	}
+	// callback của luồng liên kết provider
+	if result.LinkedIdentity != nil {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"status":          http.StatusOK,
+			"linked_identity": result.LinkedIdentity,
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":   http.StatusOK,
+		"token":    result.Token,
+		"userinfo": result.User,
+	})
+}
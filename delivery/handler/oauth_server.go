@@ -0,0 +1,441 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/johnquangdev/oauth2/middleware"
+	"github.com/johnquangdev/oauth2/usecase/interfaces"
+	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type oAuthServerHandler struct {
+	validate   *validator.Validate
+	useCase    interfaces.UseCaseImpl
+	config     utils.Config
+	middleware middleware.MiddlewareCustom
+}
+
+// RegisterOAuthServerHandler đăng ký các endpoint authorization server dưới /v1/oauth.
+// Response theo đúng RFC tương ứng nên không dùng format {"status": ...} như các API khác.
+func RegisterOAuthServerHandler(u interfaces.UseCaseImpl, g *echo.Group, v *validator.Validate, cfg utils.Config, m middleware.MiddlewareCustom) {
+	r := &oAuthServerHandler{
+		useCase:    u,
+		validate:   v,
+		config:     cfg,
+		middleware: m,
+	}
+	g.GET("/authorize", r.handlerAuthorize)
+	g.POST("/par", r.handlerPushedAuthorization)
+	g.GET("/login", r.handlerLoginPage)
+	g.GET("/consent", r.handlerConsentPage)
+	g.POST("/consent", r.handlerConsent)
+	g.POST("/token", r.handlerToken)
+	g.POST("/device_authorization", r.handlerDeviceAuthorization)
+	g.GET("/device", r.handlerDevicePage)
+	g.POST("/device", r.handlerDeviceApprove)
+	g.GET("/userinfo", r.handlerUserInfo)
+	g.POST("/userinfo", r.handlerUserInfo)
+	g.POST("/introspect", r.handlerIntrospect)
+	g.POST("/revoke", r.handlerRevoke)
+	g.GET("/end_session", r.handlerEndSession)
+	g.POST("/end_session", r.handlerEndSession)
+	if cfg.DynamicClientRegistration {
+		g.POST("/register", r.handlerRegister)
+	}
+}
+
+// loginPage là trang chọn upstream provider để đăng nhập trong luồng /v1/oauth/authorize
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in to {{.ClientName}}</title></head>
+<body>
+<h1>Sign in to {{.ClientName}}</h1>
+{{if .Scopes}}<p>{{.ClientName}} is requesting: {{range $i, $s := .Scopes}}{{if $i}}, {{end}}{{$s}}{{end}}</p>{{end}}
+<ul>
+{{range .Links}}<li><a href="{{.URL}}">Continue with {{.Provider}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+type loginPageLink struct {
+	Provider string
+	URL      string
+}
+
+// @Summary Authorization endpoint (RFC 6749 mục 4.1, PKCE bắt buộc)
+// @Description Bắt đầu authorization code flow. Trình duyệt đã đăng nhập (cookie oauth2_sso) được redirect ngay về redirect_uri kèm code, hoặc tới màn hình đồng ý nếu user chưa cấp đủ scope cho client bên thứ ba; chưa đăng nhập thì được đưa tới trang chọn provider.
+// @Tags OAuth Server
+// @Param response_type query string true "code"
+// @Param client_id query string true "client id"
+// @Param redirect_uri query string false "phải khớp chính xác một redirect uri đã đăng ký"
+// @Param scope query string false "danh sách scope phân cách bằng khoảng trắng"
+// @Param state query string false "trả lại nguyên vẹn cho client"
+// @Param nonce query string false "nonce"
+// @Param code_challenge query string true "BASE64URL(SHA256(code_verifier))"
+// @Param code_challenge_method query string true "S256"
+// @Param prompt query string false "none, login hoặc consent"
+// @Param request query string false "request object đã ký (RFC 9101), thay cho các tham số trên"
+// @Param request_uri query string false "request_uri nhận từ /v1/oauth/par (RFC 9126)"
+// @Success 302
+// @Failure 400 {object} map[string]interface{}
+// @Router /v1/oauth/authorize [get]
+func (h *oAuthServerHandler) handlerAuthorize(c echo.Context) error {
+	result, err := h.useCase.Auth().OAuthServer.Authorize(c.Request().Context(), models.AuthorizeRequest{
+		ResponseType:        c.QueryParam("response_type"),
+		ClientId:            c.QueryParam("client_id"),
+		RedirectUri:         c.QueryParam("redirect_uri"),
+		Scope:               c.QueryParam("scope"),
+		State:               c.QueryParam("state"),
+		Nonce:               c.QueryParam("nonce"),
+		CodeChallenge:       c.QueryParam("code_challenge"),
+		CodeChallengeMethod: c.QueryParam("code_challenge_method"),
+		Prompt:              c.QueryParam("prompt"),
+		Request:             c.QueryParam("request"),
+		RequestUri:          c.QueryParam("request_uri"),
+		SSOSessionId:        getSSOSessionCookie(c),
+		Client:              clientInfo(c),
+	})
+	if err != nil {
+		// client_id / redirect_uri chưa xác minh thì báo lỗi cho user, không redirect
+		switch {
+		case errors.Is(err, models.ErrUnknownClient), errors.Is(err, models.ErrInvalidRedirectUri):
+			return oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		case errors.Is(err, models.ErrInvalidRequestUri):
+			return oauthError(c, http.StatusBadRequest, "invalid_request_uri", err.Error())
+		case errors.Is(err, models.ErrInvalidRequestObject):
+			return oauthError(c, http.StatusBadRequest, "invalid_request_object", err.Error())
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	if result.LoginURL != "" {
+		return c.Redirect(http.StatusFound, result.LoginURL)
+	}
+	if result.ConsentURL != "" {
+		return c.Redirect(http.StatusFound, result.ConsentURL)
+	}
+	return c.Redirect(http.StatusFound, result.RedirectURL)
+}
+
+// @Summary Pushed authorization request (RFC 9126)
+// @Description Client đã xác thực gửi tham số authorization trực tiếp tới server, nhận request_uri dùng một lần để gọi /v1/oauth/authorize?client_id=...&request_uri=...
+// @Tags OAuth Server
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param response_type formData string false "code"
+// @Param redirect_uri formData string false "phải khớp chính xác một redirect uri đã đăng ký"
+// @Param scope formData string false "danh sách scope phân cách bằng khoảng trắng"
+// @Param state formData string false "trả lại nguyên vẹn cho client"
+// @Param nonce formData string false "nonce"
+// @Param code_challenge formData string false "BASE64URL(SHA256(code_verifier))"
+// @Param code_challenge_method formData string false "S256"
+// @Param prompt formData string false "none, login hoặc consent"
+// @Param request formData string false "request object đã ký (RFC 9101), thay cho các tham số trên"
+// @Success 201 {object} models.PushedAuthorizationResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /v1/oauth/par [post]
+func (h *oAuthServerHandler) handlerPushedAuthorization(c echo.Context) error {
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		return oauthClientError(c, err)
+	}
+	if clientId := c.FormValue("client_id"); clientId != "" && clientId != client.ClientId {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "client_id does not match the authenticated client")
+	}
+	result, err := h.useCase.Auth().OAuthServer.PushAuthorizationRequest(c.Request().Context(), client, models.AuthorizeRequest{
+		ResponseType:        c.FormValue("response_type"),
+		ClientId:            client.ClientId,
+		RedirectUri:         c.FormValue("redirect_uri"),
+		Scope:               c.FormValue("scope"),
+		State:               c.FormValue("state"),
+		Nonce:               c.FormValue("nonce"),
+		CodeChallenge:       c.FormValue("code_challenge"),
+		CodeChallengeMethod: c.FormValue("code_challenge_method"),
+		Prompt:              c.FormValue("prompt"),
+		Request:             c.FormValue("request"),
+		RequestUri:          c.FormValue("request_uri"),
+	})
+	if err != nil {
+		var oauthErr *models.OAuthError
+		switch {
+		case errors.As(err, &oauthErr):
+			return oauthError(c, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+		case errors.Is(err, models.ErrInvalidRedirectUri):
+			return oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		case errors.Is(err, models.ErrInvalidRequestObject):
+			return oauthError(c, http.StatusBadRequest, "invalid_request_object", err.Error())
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusCreated, result)
+}
+
+// @Summary Trang chọn provider đăng nhập
+// @Description Trang HTML liệt kê các upstream provider cho authorization request đang chờ đăng nhập
+// @Tags OAuth Server
+// @Produce html
+// @Param request_id query string true "id authorization request"
+// @Success 200
+// @Failure 400 {object} map[string]interface{}
+// @Router /v1/oauth/login [get]
+func (h *oAuthServerHandler) handlerLoginPage(c echo.Context) error {
+	info, err := h.useCase.Auth().OAuthServer.GetAuthorizationRequest(c.Request().Context(), c.QueryParam("request_id"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidAuthzRequest) {
+			return oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	var links []loginPageLink
+	for _, name := range h.useCase.Auth().OAuth2.Providers() {
+		links = append(links, loginPageLink{
+			Provider: name,
+			URL:      "/v1/auth/" + url.PathEscape(name) + "/login?" + url.Values{"authorization_request": {info.Id}}.Encode(),
+		})
+	}
+	var page bytes.Buffer
+	err = loginPage.Execute(&page, map[string]interface{}{
+		"ClientName": info.ClientName,
+		"Scopes":     info.Scopes,
+		"Links":      links,
+	})
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.HTMLBlob(http.StatusOK, page.Bytes())
+}
+
+// @Summary Token endpoint (RFC 6749 mục 4.1.3 và 6)
+// @Description Đổi authorization code (kèm code_verifier), refresh token hoặc access token của user (token exchange) lấy access token. Client confidential xác thực bằng HTTP Basic hoặc form, client public chỉ gửi client_id.
+// @Tags OAuth Server
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "authorization_code, refresh_token, client_credentials, device code hoặc token exchange"
+// @Param code formData string false "authorization code"
+// @Param redirect_uri formData string false "redirect uri đã dùng ở /authorize"
+// @Param code_verifier formData string false "PKCE code verifier"
+// @Param refresh_token formData string false "refresh token"
+// @Param scope formData string false "scope xin cho client_credentials hoặc token exchange"
+// @Param device_code formData string false "device code (grant urn:ietf:params:oauth:grant-type:device_code)"
+// @Param subject_token formData string false "access token của user (grant urn:ietf:params:oauth:grant-type:token-exchange)"
+// @Param subject_token_type formData string false "urn:ietf:params:oauth:token-type:access_token"
+// @Param actor_token formData string false "access token của bên đang dùng token thay user"
+// @Param actor_token_type formData string false "urn:ietf:params:oauth:token-type:access_token"
+// @Param audience formData string false "audience của token mới, phải nằm trong token_exchange_audiences của client"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /v1/oauth/token [post]
+func (h *oAuthServerHandler) handlerToken(c echo.Context) error {
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		return oauthClientError(c, err)
+	}
+	// audience và resource của token exchange có thể lặp lại nhiều lần
+	form, err := c.FormParams()
+	if err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+	}
+	result, err := h.useCase.Auth().OAuthServer.Token(c.Request().Context(), client, models.TokenRequest{
+		GrantType:          form.Get("grant_type"),
+		Code:               form.Get("code"),
+		RedirectUri:        form.Get("redirect_uri"),
+		CodeVerifier:       form.Get("code_verifier"),
+		RefreshToken:       form.Get("refresh_token"),
+		Scope:              form.Get("scope"),
+		DeviceCode:         form.Get("device_code"),
+		SubjectToken:       form.Get("subject_token"),
+		SubjectTokenType:   form.Get("subject_token_type"),
+		ActorToken:         form.Get("actor_token"),
+		ActorTokenType:     form.Get("actor_token_type"),
+		RequestedTokenType: form.Get("requested_token_type"),
+		Audience:           form["audience"],
+		Resource:           form["resource"],
+	})
+	if err != nil {
+		var oauthErr *models.OAuthError
+		if errors.As(err, &oauthErr) {
+			return oauthError(c, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+	return c.JSON(http.StatusOK, result)
+}
+
+// @Summary Token introspection (RFC 7662)
+// @Description Kiểm tra token tập trung cho resource server. Client xác thực bằng HTTP Basic hoặc client_id/client_secret trong form.
+// @Tags OAuth Server
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "access token hoặc refresh token"
+// @Param token_type_hint formData string false "access_token hoặc refresh_token"
+// @Success 200 {object} models.Introspection
+// @Failure 401 {object} map[string]interface{}
+// @Router /v1/oauth/introspect [post]
+func (h *oAuthServerHandler) handlerIntrospect(c echo.Context) error {
+	ctx := c.Request().Context()
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		return oauthClientError(c, err)
+	}
+	// introspection chỉ dành cho resource server có secret
+	if client.IsPublic {
+		return oauthClientError(c, models.ErrInvalidClient)
+	}
+	token := c.FormValue("token")
+	if token == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "token is required")
+	}
+	result, err := h.useCase.Auth().OAuthServer.Introspect(ctx, token)
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, result)
+}
+
+// @Summary Token revocation (RFC 7009)
+// @Description Thu hồi access token (theo jti) hoặc refresh token (cả session). Luôn trả 200 kể cả khi token không hợp lệ.
+// @Tags OAuth Server
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "access token hoặc refresh token"
+// @Param token_type_hint formData string false "access_token hoặc refresh_token"
+// @Success 200
+// @Failure 401 {object} map[string]interface{}
+// @Router /v1/oauth/revoke [post]
+func (h *oAuthServerHandler) handlerRevoke(c echo.Context) error {
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		return oauthClientError(c, err)
+	}
+	token := c.FormValue("token")
+	if token == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "token is required")
+	}
+	if err := h.useCase.Auth().OAuthServer.Revoke(c.Request().Context(), client, token, c.FormValue("token_type_hint")); err != nil {
+		return oauthError(c, http.StatusServiceUnavailable, "temporarily_unavailable", err.Error())
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// @Summary OpenID Connect UserInfo
+// @Description Claims của user theo scope của access token: sub, thêm name/picture/updated_at với profile, email/email_verified với email
+// @Tags OAuth Server
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Router /v1/oauth/userinfo [get]
+func (h *oAuthServerHandler) handlerUserInfo(c echo.Context) error {
+	token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok || token == "" {
+		c.Response().Header().Set("WWW-Authenticate", `Bearer realm="oauth"`)
+		return oauthError(c, http.StatusUnauthorized, "invalid_request", "bearer access token is required")
+	}
+	claims, err := h.useCase.Auth().OAuthServer.UserInfo(c.Request().Context(), token)
+	if err != nil {
+		// lỗi theo RFC 6750 mục 3.1
+		switch {
+		case errors.Is(err, models.ErrInvalidToken):
+			c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			return oauthError(c, http.StatusUnauthorized, "invalid_token", err.Error())
+		case errors.Is(err, models.ErrInsufficientScope):
+			c.Response().Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+			return oauthError(c, http.StatusForbidden, "insufficient_scope", err.Error())
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, claims)
+}
+
+// @Summary Dynamic client registration (RFC 7591)
+// @Description Chỉ bật khi DYNAMIC_CLIENT_REGISTRATION=true. Nếu có DYNAMIC_REGISTRATION_TOKEN thì phải gửi token đó dạng Bearer.
+// @Tags OAuth Server
+// @Accept json
+// @Produce json
+// @Param body body models.ClientRegistrationRequest true "client metadata"
+// @Success 201 {object} models.ClientRegistrationResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /v1/oauth/register [post]
+func (h *oAuthServerHandler) handlerRegister(c echo.Context) error {
+	if h.config.DynamicRegistrationToken != "" {
+		token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.config.DynamicRegistrationToken)) != 1 {
+			c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			return oauthError(c, http.StatusUnauthorized, "invalid_token", "a valid initial access token is required")
+		}
+	}
+	var req models.ClientRegistrationRequest
+	if err := c.Bind(&req); err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_client_metadata", err.Error())
+	}
+	result, err := h.useCase.Auth().OAuthServer.RegisterClient(c.Request().Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidClientRedirect):
+			return oauthError(c, http.StatusBadRequest, "invalid_redirect_uri", err.Error())
+		case errors.Is(err, models.ErrInvalidClientMetadata):
+			return oauthError(c, http.StatusBadRequest, "invalid_client_metadata", err.Error())
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusCreated, result)
+}
+
+// authenticateClient hỗ trợ client_secret_basic và client_secret_post (RFC 6749 mục 2.3.1)
+func (h *oAuthServerHandler) authenticateClient(c echo.Context) (*models.Client, error) {
+	clientId, clientSecret, ok := c.Request().BasicAuth()
+	if ok {
+		// client_id và secret trong Basic header được form-urlencode trước khi base64
+		var err error
+		if clientId, err = url.QueryUnescape(clientId); err != nil {
+			return nil, models.ErrInvalidClient
+		}
+		if clientSecret, err = url.QueryUnescape(clientSecret); err != nil {
+			return nil, models.ErrInvalidClient
+		}
+	} else {
+		clientId = c.FormValue("client_id")
+		clientSecret = c.FormValue("client_secret")
+	}
+	return h.useCase.Auth().OAuthServer.AuthenticateClient(c.Request().Context(), clientId, clientSecret)
+}
+
+// oauthError trả lỗi theo format của RFC 6749 mục 5.2
+func oauthError(c echo.Context, status int, code string, description string) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	body := map[string]interface{}{
+		"error": code,
+	}
+	if description != "" {
+		body["error_description"] = description
+	}
+	return c.JSON(status, body)
+}
+
+func oauthClientError(c echo.Context, err error) error {
+	if errors.Is(err, models.ErrInvalidClient) {
+		c.Response().Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		return oauthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+	}
+	return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/johnquangdev/oauth2/middleware"
+	"github.com/johnquangdev/oauth2/usecase/interfaces"
+	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type sessionHandler struct {
+	validate   *validator.Validate
+	useCase    interfaces.UseCaseImpl
+	config     utils.Config
+	middleware middleware.MiddlewareCustom
+}
+
+func RegisterSessionHandler(u interfaces.UseCaseImpl, g *echo.Group, v *validator.Validate, cfg utils.Config, m middleware.MiddlewareCustom) {
+	r := &sessionHandler{
+		useCase:    u,
+		validate:   v,
+		config:     cfg,
+		middleware: m,
+	}
+	sessions := g.Group("/sessions", m.JWTAuthMiddleware())
+
+	sessions.GET("", r.handlerListSessions)
+	sessions.DELETE("/:id", r.handlerRevokeSession)
+	sessions.DELETE("", r.handlerRevokeAllSessions)
+}
+
+// @Summary Danh sách phiên đăng nhập
+// @Description Trả về các phiên còn hiệu lực của user (thiết bị, IP, user agent, thời gian tạo và dùng gần nhất)
+// @Tags Session
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /v1/auth/sessions [get]
+func (h *sessionHandler) handlerListSessions(c echo.Context) error {
+	userId, ok := c.Get("claims").(uuid.UUID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "userId not found in context")
+	}
+	currentSessionId, _ := c.Get("session_id").(uuid.UUID)
+
+	sessions, err := h.useCase.Auth().SystemAuth.ListSessions(c.Request().Context(), userId, currentSessionId)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":   http.StatusOK,
+		"sessions": sessions,
+	})
+}
+
+// @Summary Thu hồi một phiên đăng nhập
+// @Description Revoke refresh token và mọi access token còn hạn của phiên
+// @Tags Session
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "session id"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /v1/auth/sessions/{id} [delete]
+func (h *sessionHandler) handlerRevokeSession(c echo.Context) error {
+	userId, ok := c.Get("claims").(uuid.UUID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "userId not found in context")
+	}
+	sessionId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"message": "invalid session id",
+		})
+	}
+	if err := h.useCase.Auth().SystemAuth.RevokeSession(c.Request().Context(), userId, sessionId); err != nil {
+		if errors.Is(err, models.ErrSessionNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"status":  http.StatusNotFound,
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":  http.StatusOK,
+		"message": "session revoked",
+	})
+}
+
+// @Summary Đăng xuất khỏi mọi thiết bị
+// @Description Revoke tất cả phiên đăng nhập của user, kể cả phiên hiện tại
+// @Tags Session
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /v1/auth/sessions [delete]
+func (h *sessionHandler) handlerRevokeAllSessions(c echo.Context) error {
+	userId, ok := c.Get("claims").(uuid.UUID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "userId not found in context")
+	}
+	if err := h.useCase.Auth().SystemAuth.RevokeAllSessions(c.Request().Context(), userId); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": http.StatusInternalServerError,
+			"detail": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":  http.StatusOK,
+		"message": "all sessions revoked",
+	})
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/johnquangdev/oauth2/middleware"
+	"github.com/johnquangdev/oauth2/usecase/interfaces"
+	"github.com/johnquangdev/oauth2/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type wellKnownHandler struct {
+	validate   *validator.Validate
+	useCase    interfaces.UseCaseImpl
+	config     utils.Config
+	middleware middleware.MiddlewareCustom
+}
+
+// RegisterWellKnownHandler đăng ký các endpoint công khai dưới /.well-known
+func RegisterWellKnownHandler(u interfaces.UseCaseImpl, g *echo.Group, v *validator.Validate, cfg utils.Config, m middleware.MiddlewareCustom) {
+	r := &wellKnownHandler{
+		useCase:    u,
+		validate:   v,
+		config:     cfg,
+		middleware: m,
+	}
+	g.GET("/jwks.json", r.handlerJWKS)
+	g.GET("/openid-configuration", r.handlerDiscovery)
+	g.GET("/oauth-authorization-server", r.handlerDiscovery)
+}
+
+// @Summary OpenID Connect discovery
+// @Description OpenID Provider metadata (cũng phục vụ ở /.well-known/oauth-authorization-server theo RFC 8414)
+// @Tags Well-Known
+// @Produce json
+// @Success 200 {object} models.ServerMetadata
+// @Router /.well-known/openid-configuration [get]
+func (h *wellKnownHandler) handlerDiscovery(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.useCase.Auth().OAuthServer.Metadata())
+}
+
+// @Summary JSON Web Key Set
+// @Description Public key để resource server verify access token (không có key khi server ký bằng HS256)
+// @Tags Well-Known
+// @Produce json
+// @Success 200 {object} utils.JSONWebKeySet
+// @Router /.well-known/jwks.json [get]
+func (h *wellKnownHandler) handlerJWKS(c echo.Context) error {
+	// resource server cache JWKS, key mới được thêm trước khi dùng để ký nên cache ngắn là đủ
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.config.JWTKeys.JWKS())
+}
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 type LoginOauth2 struct {
 	Code string `json:"code" validate:"required"`
 }
@@ -21,3 +23,30 @@ type User struct {
 type Logout struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
+
+type RefreshToken struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type ConfirmLink struct {
+	LinkToken string `json:"link_token" validate:"required"`
+}
+
+type CreateClient struct {
+	Name         string   `json:"name" validate:"required"`
+	RedirectUris []string `json:"redirect_uris" validate:"omitempty,dive,url"`
+	Scopes       []string `json:"scopes"`
+	GrantTypes   []string `json:"grant_types"`
+	IsPublic     bool     `json:"is_public"`
+	FirstParty   bool     `json:"first_party"`
+	// Jwks là JWK Set công khai để verify request object (RFC 9101)
+	Jwks                               json.RawMessage `json:"jwks"`
+	RequirePushedAuthorizationRequests bool            `json:"require_pushed_authorization_requests"`
+	RequireSignedRequestObject         bool            `json:"require_signed_request_object"`
+	PostLogoutRedirectUris             []string        `json:"post_logout_redirect_uris" validate:"omitempty,dive,url"`
+	BackchannelLogoutUri               string          `json:"backchannel_logout_uri" validate:"omitempty,url"`
+	FrontchannelLogoutUri              string          `json:"frontchannel_logout_uri" validate:"omitempty,url"`
+	TokenExchangeAudiences             []string        `json:"token_exchange_audiences"`
+	AccessTokenTimeLife                uint16          `json:"access_token_time_life"`
+	RefreshTokenTimeLife               uint16          `json:"refresh_token_time_life"`
+}
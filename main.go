@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"github.com/johnquangdev/oauth2/cmd"
+	"github.com/johnquangdev/oauth2/cmd/client"
+	"github.com/johnquangdev/oauth2/cmd/keyring"
 	"github.com/johnquangdev/oauth2/cmd/sqlmigrate"
 )
 
@@ -13,8 +15,39 @@ func main() {
 	// Define CLI flags
 	migrateDown := flag.Bool("migrate-down", false, "Run database migration rollback")
 	migrateLimit := flag.Int("limit", 1, "Number of migrations to rollback (default: 1)")
+	keyringGenerate := flag.String("keyring-generate", "", "Generate a pending JWT signing key (RS256, ES256, ES384, ES512, EdDSA)")
+	keyringPromote := flag.String("keyring-promote", "", "Make the key with this kid the active JWT signing key")
+	keyringActivateIn := flag.Duration("keyring-activate-in", 0, "Delay before the promoted key starts signing (e.g. 10m)")
+	keyringPrune := flag.Bool("keyring-prune", false, "Remove expired keys from the JWT keyring")
+	keyringList := flag.Bool("keyring-list", false, "List keys in the JWT keyring")
+	clientCreate := flag.String("client-create", "", "Create an OAuth client with this name and print its credentials")
+	clientRedirectUris := flag.String("client-redirect-uris", "", "Space-separated redirect URIs allowed for the new client")
+	clientScopes := flag.String("client-scopes", "", "Space-separated scopes the new client may request")
+	clientGrantTypes := flag.String("client-grant-types", "", "Space-separated grant types of the new client (default: authorization_code refresh_token when redirect URIs are set)")
+	clientPublic := flag.Bool("client-public", false, "Create a public client (no secret, PKCE only)")
+	clientFirstParty := flag.Bool("client-first-party", false, "Create a first-party client (users are not asked for consent)")
+	clientTokenExchangeAudiences := flag.String("client-token-exchange-audiences", "", "Space-separated audiences the new client may request with the token exchange grant")
 	flag.Parse()
 
+	// Keyring commands chỉ sửa JWT_KEYRING_DIR, server đang chạy tự đọc lại
+	switch {
+	case *keyringGenerate != "":
+		keyring.RunGenerate(*keyringGenerate)
+		os.Exit(0)
+	case *keyringPromote != "":
+		keyring.RunPromote(*keyringPromote, *keyringActivateIn)
+		os.Exit(0)
+	case *keyringPrune:
+		keyring.RunPrune()
+		os.Exit(0)
+	case *keyringList:
+		keyring.RunList()
+		os.Exit(0)
+	case *clientCreate != "":
+		client.RunCreateClient(*clientCreate, *clientRedirectUris, *clientScopes, *clientGrantTypes, *clientPublic, *clientFirstParty, *clientTokenExchangeAudiences)
+		os.Exit(0)
+	}
+
 	// Check if migrate-down flag is set
 	if *migrateDown {
 		fmt.Printf("Running migration down (limit: %d)...\n", *migrateLimit)
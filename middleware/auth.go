@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
 	"github.com/johnquangdev/oauth2/utils"
 	"github.com/labstack/echo/v4"
 )
@@ -52,29 +54,81 @@ func (m MiddlewareCustom) JWTAuthMiddleware() echo.MiddlewareFunc {
 			}
 
 			// Validate JWT token
-			claims, err := utils.VerifyToken(tokenString, m.cfg.SecretKey)
+			claims, err := utils.VerifyToken(tokenString, m.cfg, utils.TokenTypeAccess)
 			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-					"status":  http.StatusInternalServerError,
+				return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+					"status":  http.StatusUnauthorized,
 					"detail":  err.Error(),
 					"message": "token invalid",
 				})
 			}
+			// Token của user cấp cho OAuth client chỉ dành cho client đó, không dùng cho API first-party
+			if !claims.IsClient() && claims.ClientId != "" {
+				return echo.NewHTTPError(http.StatusForbidden, map[string]interface{}{
+					"status": http.StatusForbidden,
+					"error":  "token was issued to an oauth client",
+				})
+			}
 
-			// Kiểm tra token có bị blacklist không (Redis)
-			isBlacklisted, err := m.repo.Redis().IsTokenBlacklisted(claims.Id)
+			// Kiểm tra jti của token có bị blacklist không (Redis)
+			if claims.ID != "" {
+				isBlacklisted, err := m.repo.Redis().IsTokenBlacklisted(c.Request().Context(), claims.ID)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, map[string]interface{}{
+						"status": http.StatusInternalServerError,
+						"error":  "error checking token status",
+					})
+				}
+				if isBlacklisted {
+					return echo.NewHTTPError(http.StatusUnauthorized, map[string]interface{}{
+						"status": http.StatusUnauthorized,
+						"error":  "token is blocked",
+					})
+				}
+			}
+			// Token client_credentials: principal là client, không có user để kiểm tra status
+			if claims.IsClient() {
+				client, err := m.repo.Client().GetClientByClientId(c.Request().Context(), claims.ClientId)
+				if err != nil || client.DisabledAt != nil {
+					return echo.NewHTTPError(http.StatusUnauthorized, map[string]interface{}{
+						"status": http.StatusUnauthorized,
+						"error":  "client not found or disabled",
+					})
+				}
+				c.Set("client_id", claims.ClientId)
+				c.Set("scope", claims.Scope)
+				return next(c)
+			}
+			// Token phát hành trước (hoặc cùng giây với) lần "đăng xuất khỏi mọi thiết bị" gần nhất thì không còn hợp lệ
+			validAfter, err := m.repo.Redis().GetTokensValidAfter(c.Request().Context(), claims.Id)
 			if err != nil {
 				return echo.NewHTTPError(http.StatusInternalServerError, map[string]interface{}{
 					"status": http.StatusInternalServerError,
 					"error":  "error checking token status",
 				})
 			}
-			if isBlacklisted {
+			if validAfter != nil && (claims.IssuedAt == nil || !claims.IssuedAt.Time.After(*validAfter)) {
 				return echo.NewHTTPError(http.StatusUnauthorized, map[string]interface{}{
 					"status": http.StatusUnauthorized,
-					"error":  "token is blocked",
+					"error":  "token is revoked",
 				})
 			}
+			// Kiểm tra session của token đã bị revoke chưa (Redis)
+			if claims.SessionId != uuid.Nil {
+				isRevoked, err := m.repo.Redis().IsSessionRevoked(c.Request().Context(), claims.SessionId)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, map[string]interface{}{
+						"status": http.StatusInternalServerError,
+						"error":  "error checking session status",
+					})
+				}
+				if isRevoked {
+					return echo.NewHTTPError(http.StatusUnauthorized, map[string]interface{}{
+						"status": http.StatusUnauthorized,
+						"error":  "session is revoked",
+					})
+				}
+			}
 			// // Lấy user info từ database
 			user, err := m.repo.Auth().GetUserByUserId(context.Background(), claims.Id)
 			if err != nil {
@@ -95,6 +149,26 @@ func (m MiddlewareCustom) JWTAuthMiddleware() echo.MiddlewareFunc {
 			// // Set user context để các handler khác sử dụng
 			// c.Set("user", user)
 			c.Set("claims", claims.Id)
+			c.Set("session_id", claims.SessionId)
+			c.Set("role", user.Role)
+			c.Set("client_id", claims.ClientId)
+			c.Set("scope", claims.Scope)
+			return next(c)
+		}
+	}
+}
+
+// RequireAdmin dùng sau JWTAuthMiddleware, chỉ cho user có role admin đi qua
+func (m MiddlewareCustom) RequireAdmin() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, _ := c.Get("role").(string)
+			if role != models.RoleAdmin {
+				return echo.NewHTTPError(http.StatusForbidden, map[string]interface{}{
+					"status": http.StatusForbidden,
+					"error":  "admin role required",
+				})
+			}
 			return next(c)
 		}
 	}
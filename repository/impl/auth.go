@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/johnquangdev/oauth2/repository/interfaces"
 	"github.com/johnquangdev/oauth2/repository/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type repository struct {
@@ -31,32 +33,224 @@ func (r repository) GetUserByUserId(ctx context.Context, id uuid.UUID) (*models.
 
 func (r repository) GetUserByProviderAndProviderId(ctx context.Context, provider string, providerId string) (*models.User, error) {
 	var user models.User
-	if err := r.db.WithContext(ctx).Where(&models.User{Provider: provider, ProviderId: providerId}).First(&user).Error; err != nil {
+	err := r.db.WithContext(ctx).
+		Joins("JOIN user_identities ON user_identities.user_id = users.id").
+		Where("user_identities.provider = ? AND user_identities.provider_id = ?", provider, providerId).
+		First(&user).Error
+	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (r repository) CreateUser(user *models.User) error {
-	return r.db.Create(&user).Error
+// CreateUserWithIdentity tạo user cùng identity đầu tiên trong một transaction
+func (r repository) CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.UserIdentity) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserId = user.Id
+		return tx.Create(identity).Error
+	})
+}
+
+func (r repository) CreateIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+func (r repository) GetIdentityByProviderAndProviderId(ctx context.Context, provider string, providerId string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.db.WithContext(ctx).Where(&models.UserIdentity{Provider: provider, ProviderId: providerId}).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r repository) ListIdentitiesByUserId(ctx context.Context, userId uuid.UUID) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	if err := r.db.WithContext(ctx).Where(&models.UserIdentity{UserId: userId}).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// DeleteIdentity khoá row user để hai request unlink song song không xoá hết identity
+func (r repository) DeleteIdentity(ctx context.Context, userId uuid.UUID, identityId uuid.UUID) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(&models.User{Id: userId}).First(&user).Error; err != nil {
+			return err
+		}
+		if err := tx.Where(&models.UserIdentity{Id: identityId, UserId: userId}).First(&identity).Error; err != nil {
+			return err
+		}
+		var total int64
+		if err := tx.Model(&models.UserIdentity{}).Where("user_id = ?", userId).Count(&total).Error; err != nil {
+			return err
+		}
+		if total <= 1 {
+			return models.ErrLastIdentity
+		}
+		return tx.Delete(&identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// FindUsersByVerifiedEmail tìm user có identity với email (không phân biệt hoa thường) đã được provider xác minh
+func (r repository) FindUsersByVerifiedEmail(ctx context.Context, email string) ([]models.User, error) {
+	var users []models.User
+	err := r.db.WithContext(ctx).
+		Where("id IN (?)", r.db.Model(&models.UserIdentity{}).
+			Select("user_id").
+			Where("lower(email) = lower(?) AND email_verified", email)).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r repository) CreateLinkAudit(ctx context.Context, audit *models.AccountLinkAudit) error {
+	return r.db.WithContext(ctx).Create(audit).Error
 }
 
 func (r repository) CreateSession(session *models.Session) error {
 	return r.db.Create(&session).Error
 }
 
-func (r repository) BlockedUserByUserID(ctx context.Context, userID uuid.UUID) error {
+func (r repository) GetSessionById(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.WithContext(ctx).Where(&models.Session{Id: id}).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RotateRefreshToken chỉ cập nhật khi refresh token hiện tại vẫn là oldToken,
+// trả về false nếu token đã bị rotate trước đó (hoặc session đã bị revoke)
+func (r repository) RotateRefreshToken(ctx context.Context, sessionId uuid.UUID, oldToken string, newToken string, expiresAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND refresh_token = ? AND revoked_at IS NULL", sessionId, oldToken).
+		Updates(map[string]interface{}{
+			"refresh_token":            newToken,
+			"refresh_token_expires_at": expiresAt,
+			"last_used_at":             time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r repository) RevokeSession(ctx context.Context, sessionId uuid.UUID) error {
+	result := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionId).
+		Updates(map[string]interface{}{
+			"revoked_at": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	return nil
+}
+
+func (r repository) ListActiveSessionsByUserId(ctx context.Context, userId uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND refresh_token_expires_at > ?", userId, time.Now().UTC()).
+		Order("created_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// ListActiveSessionsBySSOSid trả về các session còn hiệu lực được cấp từ một phiên đăng nhập trình duyệt
+func (r repository) ListActiveSessionsBySSOSid(ctx context.Context, ssoSid string) ([]models.Session, error) {
+	var sessions []models.Session
+	// sso_sid <> '' khớp điều kiện của partial index và không bao giờ trả về session không có sid
+	err := r.db.WithContext(ctx).
+		Where("sso_sid = ? AND sso_sid <> '' AND revoked_at IS NULL", ssoSid).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSessionsByUserId revoke mọi session còn hiệu lực của user, trả về id các session vừa bị revoke
+func (r repository) RevokeSessionsByUserId(ctx context.Context, userId uuid.UUID) ([]uuid.UUID, error) {
+	var sessions []models.Session
+	result := r.db.WithContext(ctx).Model(&sessions).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Updates(map[string]interface{}{
+			"revoked_at": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+	ids := make([]uuid.UUID, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.Id)
+	}
+	return ids, nil
+}
+
+func (r repository) RevokeSessionsByClientId(ctx context.Context, clientId string) ([]uuid.UUID, error) {
+	var sessions []models.Session
+	result := r.db.WithContext(ctx).Model(&sessions).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("client_id = ? AND client_id <> '' AND revoked_at IS NULL", clientId).
+		Updates(map[string]interface{}{
+			"revoked_at": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+	ids := make([]uuid.UUID, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.Id)
+	}
+	return ids, nil
+}
+
+// RevokeSessionsByUserIdAndClientId revoke các session user đã cấp cho một client
+func (r repository) RevokeSessionsByUserIdAndClientId(ctx context.Context, userId uuid.UUID, clientId string) ([]uuid.UUID, error) {
+	var sessions []models.Session
+	result := r.db.WithContext(ctx).Model(&sessions).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("user_id = ? AND client_id = ? AND client_id <> '' AND revoked_at IS NULL", userId, clientId).
+		Updates(map[string]interface{}{
+			"revoked_at": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+	ids := make([]uuid.UUID, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.Id)
+	}
+	return ids, nil
+}
+
+func (r repository) UpdateUserStatus(ctx context.Context, userID uuid.UUID, status string) error {
 	var s *models.User
 	result := r.db.WithContext(ctx).Model(&s).
 		Where("id=?", userID).
 		Updates(map[string]interface{}{
-			"status": "blocked",
+			"status": status,
 		})
 	if result.Error != nil {
-		return fmt.Errorf("failed to blocked user: %w", result.Error)
+		return fmt.Errorf("failed to update user status: %w", result.Error)
 	}
 	if result.RowsAffected == 0 {
-		return fmt.Errorf("user does not exist")
+		return gorm.ErrRecordNotFound
 	}
 	return nil
 }
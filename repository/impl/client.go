@@ -0,0 +1,117 @@
+package impl
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type clientRepository struct {
+	db *gorm.DB
+}
+
+func NewClient(db *gorm.DB) interfaces.Client {
+	return &clientRepository{
+		db: db,
+	}
+}
+
+func (r clientRepository) CreateClient(ctx context.Context, client *models.OAuthClient) error {
+	return r.db.WithContext(ctx).Create(client).Error
+}
+
+func (r clientRepository) GetClientByClientId(ctx context.Context, clientId string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := r.db.WithContext(ctx).Where("client_id = ?", clientId).First(&client).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r clientRepository) GetClientById(ctx context.Context, id uuid.UUID) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&client).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r clientRepository) ListClients(ctx context.Context) ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+func (r clientRepository) UpdateClientSecretHash(ctx context.Context, id uuid.UUID, secretHash string) error {
+	result := r.db.WithContext(ctx).Model(&models.OAuthClient{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"client_secret_hash": secretHash,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetClientDisabledAt vô hiệu hoá client (disabledAt khác nil) hoặc kích hoạt lại (nil)
+func (r clientRepository) SetClientDisabledAt(ctx context.Context, id uuid.UUID, disabledAt *time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.OAuthClient{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"disabled_at": disabledAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r clientRepository) GetConsent(ctx context.Context, userId uuid.UUID, clientId string) (*models.UserConsent, error) {
+	var consent models.UserConsent
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND client_id = ?", userId, clientId).First(&consent).Error; err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// SaveConsent tạo mới hoặc ghi đè scope đã đồng ý của cặp user/client
+func (r clientRepository) SaveConsent(ctx context.Context, consent *models.UserConsent) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scope", "updated_at"}),
+	}).Create(consent).Error
+}
+
+func (r clientRepository) ListConsentsByUserId(ctx context.Context, userId uuid.UUID) ([]models.UserConsent, error) {
+	var consents []models.UserConsent
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userId).Order("updated_at DESC").Find(&consents).Error; err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+func (r clientRepository) DeleteConsent(ctx context.Context, userId uuid.UUID, clientId string) error {
+	result := r.db.WithContext(ctx).Where("user_id = ? AND client_id = ?", userId, clientId).Delete(&models.UserConsent{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
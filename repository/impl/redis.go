@@ -2,11 +2,14 @@ package impl
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -28,15 +31,21 @@ func (r *Redis) DeleteRefreshToken(userID string) error {
 	return nil
 }
 
-func (r *Redis) AddBackList(userId string, token string, duration time.Duration) error {
-	r.RedisClient.Set(context.Background(), userId, token, duration)
+// AddBlacklist thu hồi một token theo jti, key tự hết hạn khi token hết hạn
+func (r *Redis) AddBlacklist(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	if err := r.RedisClient.Set(ctx, "blacklist:"+jti, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to blacklist token: %w", err)
+	}
 	return nil
 }
 
-func (r *Redis) IsTokenBlacklisted(tokenID uuid.UUID) (bool, error) {
-	key := "blacklist:" + tokenID.String()
+func (r *Redis) IsTokenBlacklisted(ctx context.Context, jti string) (bool, error) {
+	key := "blacklist:" + jti
 
-	exists, err := r.RedisClient.Exists(context.Background(), key).Result()
+	exists, err := r.RedisClient.Exists(ctx, key).Result()
 	if err != nil {
 		return false, fmt.Errorf("failed to check token blacklist: %w", err)
 	}
@@ -44,10 +53,462 @@ func (r *Redis) IsTokenBlacklisted(tokenID uuid.UUID) (bool, error) {
 	return exists == 1, nil
 }
 
-func (r *Redis) CreateRecord(userId uuid.UUID, accessToken string, accessTokenTimeLife time.Duration) error {
-	status := r.RedisClient.Set(context.Background(), userId.String(), accessToken, accessTokenTimeLife)
-	if err := status.Err(); err != nil {
-		return fmt.Errorf("failed to create record: %v", err)
+// SetTokensValidAfter đánh dấu mọi token của user phát hành trước hoặc cùng giây với validAfter là không hợp lệ
+func (r *Redis) SetTokensValidAfter(ctx context.Context, userId uuid.UUID, validAfter time.Time, ttl time.Duration) error {
+	if err := r.RedisClient.Set(ctx, "tokens_valid_after:"+userId.String(), validAfter.Unix(), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set tokens valid after: %w", err)
+	}
+	return nil
+}
+
+// GetTokensValidAfter trả về nil nếu user chưa từng đăng xuất khỏi mọi thiết bị (hoặc mốc đã hết hạn)
+func (r *Redis) GetTokensValidAfter(ctx context.Context, userId uuid.UUID) (*time.Time, error) {
+	value, err := r.RedisClient.Get(ctx, "tokens_valid_after:"+userId.String()).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get tokens valid after: %w", err)
+	}
+	validAfter := time.Unix(value, 0).UTC()
+	return &validAfter, nil
+}
+
+func (r *Redis) SaveOAuthState(ctx context.Context, state string, data *models.OAuthState, ttl time.Duration) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal oauth state: %w", err)
+	}
+	if err := r.RedisClient.Set(ctx, "oauth_state:"+state, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save oauth state: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOAuthState lấy và xoá state (GETDEL) nên mỗi state chỉ dùng được một lần.
+// Trả về nil nếu state không tồn tại, đã hết hạn hoặc đã được dùng.
+func (r *Redis) ConsumeOAuthState(ctx context.Context, state string) (*models.OAuthState, error) {
+	value, err := r.RedisClient.GetDel(ctx, "oauth_state:"+state).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get oauth state: %w", err)
+	}
+	var data models.OAuthState
+	if err := json.Unmarshal(value, &data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal oauth state: %w", err)
+	}
+	return &data, nil
+}
+
+func (r *Redis) SavePendingLink(ctx context.Context, token string, data *models.PendingLink, ttl time.Duration) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending link: %w", err)
+	}
+	if err := r.RedisClient.Set(ctx, "pending_link:"+token, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save pending link: %w", err)
+	}
+	return nil
+}
+
+// ConsumePendingLink lấy và xoá yêu cầu liên kết, trả về nil nếu không tồn tại hoặc đã hết hạn
+func (r *Redis) ConsumePendingLink(ctx context.Context, token string) (*models.PendingLink, error) {
+	value, err := r.RedisClient.GetDel(ctx, "pending_link:"+token).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get pending link: %w", err)
+	}
+	var data models.PendingLink
+	if err := json.Unmarshal(value, &data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pending link: %w", err)
+	}
+	return &data, nil
+}
+
+// RevokeSessionTokens đánh dấu session đã bị revoke để access token còn hạn của session bị từ chối ngay
+func (r *Redis) RevokeSessionTokens(ctx context.Context, sessionId uuid.UUID, ttl time.Duration) error {
+	if err := r.RedisClient.Set(ctx, "revoked_session:"+sessionId.String(), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke session tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *Redis) IsSessionRevoked(ctx context.Context, sessionId uuid.UUID) (bool, error) {
+	exists, err := r.RedisClient.Exists(ctx, "revoked_session:"+sessionId.String()).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check revoked session: %w", err)
+	}
+	return exists == 1, nil
+}
+
+// setJSON/getJSON dùng chung cho các bản ghi dạng JSON có TTL trong redis
+func (r *Redis) setJSON(ctx context.Context, key string, data interface{}, ttl time.Duration) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", key, err)
+	}
+	if err := r.RedisClient.Set(ctx, key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save %s: %w", key, err)
+	}
+	return nil
+}
+
+// getJSON trả về false nếu key không tồn tại hoặc đã hết hạn, consume=true thì xoá luôn (GETDEL)
+func (r *Redis) getJSON(ctx context.Context, key string, consume bool, dest interface{}) (bool, error) {
+	var cmd *redis.StringCmd
+	if consume {
+		cmd = r.RedisClient.GetDel(ctx, key)
+	} else {
+		cmd = r.RedisClient.Get(ctx, key)
+	}
+	value, err := cmd.Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	if err := json.Unmarshal(value, dest); err != nil {
+		return false, fmt.Errorf("failed to unmarshal %s: %w", key, err)
+	}
+	return true, nil
+}
+
+func (r *Redis) SaveAuthorizationRequest(ctx context.Context, id string, data *models.AuthorizationRequest, ttl time.Duration) error {
+	return r.setJSON(ctx, "authz_request:"+id, data, ttl)
+}
+
+// GetAuthorizationRequest đọc request mà không xoá (trang chọn provider có thể được tải lại)
+func (r *Redis) GetAuthorizationRequest(ctx context.Context, id string) (*models.AuthorizationRequest, error) {
+	var data models.AuthorizationRequest
+	found, err := r.getJSON(ctx, "authz_request:"+id, false, &data)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// UpdateAuthorizationRequest ghi đè request còn hạn và giữ nguyên TTL, trả về false nếu
+// request không còn
+func (r *Redis) UpdateAuthorizationRequest(ctx context.Context, id string, data *models.AuthorizationRequest) (bool, error) {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal authz_request:%s: %w", id, err)
+	}
+	err = r.RedisClient.SetArgs(ctx, "authz_request:"+id, value, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to set authz_request:%s: %w", id, err)
+	}
+	return true, nil
+}
+
+func (r *Redis) ConsumeAuthorizationRequest(ctx context.Context, id string) (*models.AuthorizationRequest, error) {
+	var data models.AuthorizationRequest
+	found, err := r.getJSON(ctx, "authz_request:"+id, true, &data)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// SavePushedAuthorizationRequest lưu request đã push qua PAR (RFC 9126), tách key với
+// authz_request để request_uri không dùng được như id của trang đăng nhập
+func (r *Redis) SavePushedAuthorizationRequest(ctx context.Context, id string, data *models.AuthorizationRequest, ttl time.Duration) error {
+	return r.setJSON(ctx, "par:"+id, data, ttl)
+}
+
+// ConsumePushedAuthorizationRequest lấy và xoá request, request_uri chỉ dùng được một lần
+func (r *Redis) ConsumePushedAuthorizationRequest(ctx context.Context, id string) (*models.AuthorizationRequest, error) {
+	var data models.AuthorizationRequest
+	found, err := r.getJSON(ctx, "par:"+id, true, &data)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (r *Redis) SaveAuthorizationCode(ctx context.Context, code string, data *models.AuthorizationCode, ttl time.Duration) error {
+	return r.setJSON(ctx, "authz_code:"+code, data, ttl)
+}
+
+var consumeAuthorizationCodeScript = redis.NewScript(`
+local value = redis.call('GETDEL', KEYS[1])
+if value then
+	redis.call('SET', KEYS[2], '', 'PX', ARGV[1])
+end
+return value
+`)
+
+// ConsumeAuthorizationCode lấy và xoá code nên mỗi code chỉ đổi được token một lần, đồng thời
+// để lại dấu authz_code_used trong usedTTL để nhận ra code bị gửi lại
+func (r *Redis) ConsumeAuthorizationCode(ctx context.Context, code string, usedTTL time.Duration) (*models.AuthorizationCode, error) {
+	value, err := consumeAuthorizationCodeScript.Run(ctx, r.RedisClient,
+		[]string{"authz_code:" + code, "authz_code_used:" + code}, usedTTL.Milliseconds()).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+	var data models.AuthorizationCode
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal authorization code: %w", err)
+	}
+	return &data, nil
+}
+
+// authorizationCodeReplayed là giá trị dấu code đã dùng khi code bị gửi lại trước lúc
+// session cấp từ code kịp ghi vào
+const authorizationCodeReplayed = "replayed"
+
+var setAuthorizationCodeSessionScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if value == ARGV[2] then
+	return 1
+end
+if value then
+	redis.call('SET', KEYS[1], ARGV[1], 'KEEPTTL')
+end
+return 0
+`)
+
+// SetAuthorizationCodeSession ghi session đã cấp từ code vào dấu code đã dùng, trả về true
+// nếu code đã bị gửi lại trong lúc đổi token
+func (r *Redis) SetAuthorizationCodeSession(ctx context.Context, code string, sessionId uuid.UUID) (bool, error) {
+	replayed, err := setAuthorizationCodeSessionScript.Run(ctx, r.RedisClient, []string{"authz_code_used:" + code},
+		sessionId.String(), authorizationCodeReplayed).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to set authorization code session: %w", err)
+	}
+	return replayed == 1, nil
+}
+
+var replayAuthorizationCodeScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if value == '' then
+	redis.call('SET', KEYS[1], ARGV[1], 'KEEPTTL')
+end
+return value
+`)
+
+// ReplayAuthorizationCode đánh dấu code đã dùng bị gửi lại và trả về session đã cấp từ code
+// (uuid.Nil nếu chưa có). found=false nếu code chưa từng được đổi hoặc dấu đã hết hạn.
+func (r *Redis) ReplayAuthorizationCode(ctx context.Context, code string) (uuid.UUID, bool, error) {
+	value, err := replayAuthorizationCodeScript.Run(ctx, r.RedisClient, []string{"authz_code_used:" + code},
+		authorizationCodeReplayed).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return uuid.Nil, false, nil
+		}
+		return uuid.Nil, false, fmt.Errorf("failed to get authorization code session: %w", err)
+	}
+	if value == "" || value == authorizationCodeReplayed {
+		return uuid.Nil, true, nil
+	}
+	sessionId, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("invalid authorization code session %q: %w", value, err)
+	}
+	return sessionId, true, nil
+}
+
+// SaveSSOSession lưu phiên theo giá trị cookie và chỉ mục sid -> cookie để end_session
+// với id_token_hint đăng xuất được cả khi request không kèm cookie
+func (r *Redis) SaveSSOSession(ctx context.Context, id string, data *models.SSOSession, ttl time.Duration) error {
+	if err := r.setJSON(ctx, "sso_session:"+id, data, ttl); err != nil {
+		return err
+	}
+	if data.Sid == "" {
+		return nil
+	}
+	if err := r.RedisClient.Set(ctx, "sso_sid:"+data.Sid, id, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save sso session sid: %w", err)
+	}
+	return nil
+}
+
+func (r *Redis) GetSSOSession(ctx context.Context, id string) (*models.SSOSession, error) {
+	var data models.SSOSession
+	found, err := r.getJSON(ctx, "sso_session:"+id, false, &data)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (r *Redis) DeleteSSOSession(ctx context.Context, id string) error {
+	if err := r.RedisClient.Del(ctx, "sso_session:"+id).Err(); err != nil {
+		return fmt.Errorf("failed to delete sso session: %w", err)
+	}
+	return nil
+}
+
+func (r *Redis) DeleteSSOSessionBySid(ctx context.Context, sid string) error {
+	id, err := r.RedisClient.GetDel(ctx, "sso_sid:"+sid).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete sso session: %w", err)
+	}
+	return r.DeleteSSOSession(ctx, id)
+}
+
+const backchannelLogoutQueue = "backchannel_logout_queue"
+
+// EnqueueBackchannelLogout đưa job vào sorted set, score là thời điểm được gửi
+func (r *Redis) EnqueueBackchannelLogout(ctx context.Context, job *models.BackchannelLogoutJob, at time.Time) error {
+	member, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode backchannel logout job: %w", err)
+	}
+	err = r.RedisClient.ZAdd(ctx, backchannelLogoutQueue, redis.Z{Score: float64(at.Unix()), Member: member}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to enqueue backchannel logout: %w", err)
+	}
+	return nil
+}
+
+// claimBackchannelLogoutsScript lấy các job đã tới hạn và dời score của chúng tới hết lease
+// trong cùng một lệnh, nên hai instance không nhận cùng một job
+var claimBackchannelLogoutsScript = redis.NewScript(`
+local members = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+for _, member in ipairs(members) do
+	redis.call('ZADD', KEYS[1], 'XX', ARGV[2], member)
+end
+return members
+`)
+
+// ClaimBackchannelLogouts lấy tối đa limit job đã tới hạn và giữ chúng trong lease. Job vẫn nằm
+// trong hàng đợi cho tới khi được Ack/Retry, instance chết giữa chừng thì job được nhận lại khi hết lease.
+func (r *Redis) ClaimBackchannelLogouts(ctx context.Context, now time.Time, lease time.Duration, limit int64) ([]models.BackchannelLogoutJob, error) {
+	members, err := claimBackchannelLogoutsScript.Run(ctx, r.RedisClient, []string{backchannelLogoutQueue},
+		now.Unix(), now.Add(lease).Unix(), limit).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim backchannel logouts: %w", err)
+	}
+	var jobs []models.BackchannelLogoutJob
+	for _, member := range members {
+		var job models.BackchannelLogoutJob
+		if err := json.Unmarshal([]byte(member), &job); err != nil {
+			// job hỏng không bao giờ gửi được, bỏ luôn khỏi hàng đợi
+			r.RedisClient.ZRem(ctx, backchannelLogoutQueue, member)
+			continue
+		}
+		job.Member = member
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// AckBackchannelLogout xoá job khỏi hàng đợi sau khi client đã nhận hoặc hết số lần thử
+func (r *Redis) AckBackchannelLogout(ctx context.Context, job *models.BackchannelLogoutJob) error {
+	if err := r.RedisClient.ZRem(ctx, backchannelLogoutQueue, job.Member).Err(); err != nil {
+		return fmt.Errorf("failed to ack backchannel logout: %w", err)
+	}
+	return nil
+}
+
+// RetryBackchannelLogout thay job cũ bằng job mới (đã tăng Attempt) với thời điểm gửi lại at
+func (r *Redis) RetryBackchannelLogout(ctx context.Context, job *models.BackchannelLogoutJob, at time.Time) error {
+	member, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode backchannel logout job: %w", err)
+	}
+	_, err = r.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, backchannelLogoutQueue, job.Member)
+		pipe.ZAdd(ctx, backchannelLogoutQueue, redis.Z{Score: float64(at.Unix()), Member: member})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reschedule backchannel logout: %w", err)
+	}
+	return nil
+}
+
+// SaveDeviceAuthorization lưu yêu cầu theo device_code và chỉ mục user_code -> device_code.
+// user_code hết hạn trước (userCodeTTL) để thiết bị đang poll vẫn nhận được expired_token.
+func (r *Redis) SaveDeviceAuthorization(ctx context.Context, deviceCode string, data *models.DeviceAuthorization, ttl time.Duration, userCodeTTL time.Duration) error {
+	if err := r.setJSON(ctx, "device_code:"+deviceCode, data, ttl); err != nil {
+		return err
+	}
+	if err := r.RedisClient.Set(ctx, "device_user_code:"+data.UserCode, deviceCode, userCodeTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save device user code: %w", err)
 	}
 	return nil
 }
+
+// decideDeviceAuthorizationScript chỉ ghi đè yêu cầu còn ở trạng thái pending, giữ nguyên TTL
+var decideDeviceAuthorizationScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current or cjson.decode(current).status ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'KEEPTTL')
+return 1
+`)
+
+// DecideDeviceAuthorization ghi quyết định approve/deny nếu yêu cầu vẫn đang pending (compare-and-set),
+// trả về false nếu đã có quyết định khác hoặc yêu cầu không còn
+func (r *Redis) DecideDeviceAuthorization(ctx context.Context, deviceCode string, data *models.DeviceAuthorization) (bool, error) {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal device authorization: %w", err)
+	}
+	updated, err := decideDeviceAuthorizationScript.Run(ctx, r.RedisClient, []string{"device_code:" + deviceCode},
+		models.DeviceStatusPending, value).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to save device authorization: %w", err)
+	}
+	return updated == 1, nil
+}
+
+func (r *Redis) GetDeviceAuthorization(ctx context.Context, deviceCode string) (*models.DeviceAuthorization, error) {
+	var data models.DeviceAuthorization
+	found, err := r.getJSON(ctx, "device_code:"+deviceCode, false, &data)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetDeviceCodeByUserCode trả về chuỗi rỗng nếu user_code không tồn tại hoặc đã hết hạn
+func (r *Redis) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (string, error) {
+	deviceCode, err := r.RedisClient.Get(ctx, "device_user_code:"+userCode).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get device user code: %w", err)
+	}
+	return deviceCode, nil
+}
+
+// ConsumeDeviceAuthorization lấy và xoá yêu cầu (kèm user_code) nên device_code chỉ đổi được token một lần
+func (r *Redis) ConsumeDeviceAuthorization(ctx context.Context, deviceCode string) (*models.DeviceAuthorization, error) {
+	var data models.DeviceAuthorization
+	found, err := r.getJSON(ctx, "device_code:"+deviceCode, true, &data)
+	if err != nil || !found {
+		return nil, err
+	}
+	if err := r.RedisClient.Del(ctx, "device_user_code:"+data.UserCode).Err(); err != nil {
+		return nil, fmt.Errorf("failed to delete device user code: %w", err)
+	}
+	return &data, nil
+}
+
+// AllowDevicePoll trả về false nếu thiết bị poll lại trước khi hết interval (slow_down)
+func (r *Redis) AllowDevicePoll(ctx context.Context, deviceCode string, interval time.Duration) (bool, error) {
+	ok, err := r.RedisClient.SetNX(ctx, "device_poll:"+deviceCode, 1, interval).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check device poll interval: %w", err)
+	}
+	return ok, nil
+}
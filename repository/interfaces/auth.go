@@ -10,20 +10,80 @@ import (
 
 type Auth interface {
 	GetUserByUserId(context.Context, uuid.UUID) (*models.User, error)
-	CreateUser(*models.User) error
+	CreateUserWithIdentity(context.Context, *models.User, *models.UserIdentity) error
+	CreateIdentity(context.Context, *models.UserIdentity) error
+	GetIdentityByProviderAndProviderId(context.Context, string, string) (*models.UserIdentity, error)
+	ListIdentitiesByUserId(context.Context, uuid.UUID) ([]models.UserIdentity, error)
+	DeleteIdentity(ctx context.Context, userId uuid.UUID, identityId uuid.UUID) (*models.UserIdentity, error)
+	FindUsersByVerifiedEmail(ctx context.Context, email string) ([]models.User, error)
+	CreateLinkAudit(context.Context, *models.AccountLinkAudit) error
 	CreateSession(*models.Session) error
+	GetSessionById(context.Context, uuid.UUID) (*models.Session, error)
+	RotateRefreshToken(ctx context.Context, sessionId uuid.UUID, oldToken string, newToken string, expiresAt time.Time) (bool, error)
+	RevokeSession(context.Context, uuid.UUID) error
+	ListActiveSessionsByUserId(context.Context, uuid.UUID) ([]models.Session, error)
+	ListActiveSessionsBySSOSid(ctx context.Context, ssoSid string) ([]models.Session, error)
+	RevokeSessionsByUserId(context.Context, uuid.UUID) ([]uuid.UUID, error)
+	RevokeSessionsByClientId(ctx context.Context, clientId string) ([]uuid.UUID, error)
+	RevokeSessionsByUserIdAndClientId(ctx context.Context, userId uuid.UUID, clientId string) ([]uuid.UUID, error)
 	UserExists(string) (bool, error)
-	BlockedUserByUserID(context.Context, uuid.UUID) error
+	UpdateUserStatus(ctx context.Context, userId uuid.UUID, status string) error
 	GetUserByProviderAndProviderId(context.Context, string, string) (*models.User, error)
 }
 
 type Redis interface {
-	AddBackList(userID string, token string, duration time.Duration) error
-	IsTokenBlacklisted(tokenID uuid.UUID) (bool, error)
-	CreateRecord(userId uuid.UUID, accessToken string, accessTokenTimeLife time.Duration) error
+	AddBlacklist(ctx context.Context, jti string, ttl time.Duration) error
+	IsTokenBlacklisted(ctx context.Context, jti string) (bool, error)
+	SetTokensValidAfter(ctx context.Context, userId uuid.UUID, validAfter time.Time, ttl time.Duration) error
+	GetTokensValidAfter(ctx context.Context, userId uuid.UUID) (*time.Time, error)
+	SaveAuthorizationRequest(ctx context.Context, id string, data *models.AuthorizationRequest, ttl time.Duration) error
+	GetAuthorizationRequest(ctx context.Context, id string) (*models.AuthorizationRequest, error)
+	UpdateAuthorizationRequest(ctx context.Context, id string, data *models.AuthorizationRequest) (bool, error)
+	ConsumeAuthorizationRequest(ctx context.Context, id string) (*models.AuthorizationRequest, error)
+	SavePushedAuthorizationRequest(ctx context.Context, id string, data *models.AuthorizationRequest, ttl time.Duration) error
+	ConsumePushedAuthorizationRequest(ctx context.Context, id string) (*models.AuthorizationRequest, error)
+	SaveAuthorizationCode(ctx context.Context, code string, data *models.AuthorizationCode, ttl time.Duration) error
+	ConsumeAuthorizationCode(ctx context.Context, code string, usedTTL time.Duration) (*models.AuthorizationCode, error)
+	SetAuthorizationCodeSession(ctx context.Context, code string, sessionId uuid.UUID) (bool, error)
+	ReplayAuthorizationCode(ctx context.Context, code string) (uuid.UUID, bool, error)
+	SaveSSOSession(ctx context.Context, id string, data *models.SSOSession, ttl time.Duration) error
+	GetSSOSession(ctx context.Context, id string) (*models.SSOSession, error)
+	DeleteSSOSession(ctx context.Context, id string) error
+	DeleteSSOSessionBySid(ctx context.Context, sid string) error
+	EnqueueBackchannelLogout(ctx context.Context, job *models.BackchannelLogoutJob, at time.Time) error
+	ClaimBackchannelLogouts(ctx context.Context, now time.Time, lease time.Duration, limit int64) ([]models.BackchannelLogoutJob, error)
+	AckBackchannelLogout(ctx context.Context, job *models.BackchannelLogoutJob) error
+	RetryBackchannelLogout(ctx context.Context, job *models.BackchannelLogoutJob, at time.Time) error
+	SaveDeviceAuthorization(ctx context.Context, deviceCode string, data *models.DeviceAuthorization, ttl time.Duration, userCodeTTL time.Duration) error
+	DecideDeviceAuthorization(ctx context.Context, deviceCode string, data *models.DeviceAuthorization) (bool, error)
+	GetDeviceAuthorization(ctx context.Context, deviceCode string) (*models.DeviceAuthorization, error)
+	GetDeviceCodeByUserCode(ctx context.Context, userCode string) (string, error)
+	ConsumeDeviceAuthorization(ctx context.Context, deviceCode string) (*models.DeviceAuthorization, error)
+	AllowDevicePoll(ctx context.Context, deviceCode string, interval time.Duration) (bool, error)
+	SaveOAuthState(ctx context.Context, state string, data *models.OAuthState, ttl time.Duration) error
+	ConsumeOAuthState(ctx context.Context, state string) (*models.OAuthState, error)
+	SavePendingLink(ctx context.Context, token string, data *models.PendingLink, ttl time.Duration) error
+	ConsumePendingLink(ctx context.Context, token string) (*models.PendingLink, error)
+	RevokeSessionTokens(ctx context.Context, sessionId uuid.UUID, ttl time.Duration) error
+	IsSessionRevoked(ctx context.Context, sessionId uuid.UUID) (bool, error)
+}
+
+// Client là các OAuth client của authorization server
+type Client interface {
+	CreateClient(context.Context, *models.OAuthClient) error
+	GetClientByClientId(context.Context, string) (*models.OAuthClient, error)
+	GetClientById(context.Context, uuid.UUID) (*models.OAuthClient, error)
+	ListClients(context.Context) ([]models.OAuthClient, error)
+	UpdateClientSecretHash(ctx context.Context, id uuid.UUID, secretHash string) error
+	SetClientDisabledAt(ctx context.Context, id uuid.UUID, disabledAt *time.Time) error
+	GetConsent(ctx context.Context, userId uuid.UUID, clientId string) (*models.UserConsent, error)
+	SaveConsent(context.Context, *models.UserConsent) error
+	ListConsentsByUserId(context.Context, uuid.UUID) ([]models.UserConsent, error)
+	DeleteConsent(ctx context.Context, userId uuid.UUID, clientId string) error
 }
 
 type Repo interface {
 	Auth() Auth
+	Client() Client
 	Redis() Redis
 }
@@ -7,33 +7,102 @@ import (
 )
 
 type Status string
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	Id         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	Email      string    `gorm:"type:text;not null;unique" json:"email"`
-	Name       string    `gorm:"type:text" json:"name"`
-	Avatar     string    `gorm:"type:text" json:"avatar"`
-	Status     string    `gorm:"type:text; check:status IN ('active','blocked','banned')"`
-	Provider   string    `gorm:"type:text;not null" json:"provider"`
-	ProviderId string    `gorm:"type:text;not null" json:"provider_id"`
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	Id        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Email     string    `gorm:"type:text;not null;unique" json:"email"`
+	Name      string    `gorm:"type:text" json:"name"`
+	Avatar    string    `gorm:"type:text" json:"avatar"`
+	Status    string    `gorm:"type:text; check:status IN ('active','blocked','banned')"`
+	Role      string    `gorm:"type:text;not null;default:user" json:"role"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (User) TableUsers() string {
 	return "users"
 }
 
+// UserIdentity là một tài khoản provider được liên kết với user
+type UserIdentity struct {
+	Id            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserId        uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	Provider      string    `gorm:"type:text;not null" json:"provider"`
+	ProviderId    string    `gorm:"type:text;not null" json:"provider_id"`
+	Email         string    `gorm:"type:text" json:"email"`
+	EmailVerified bool      `gorm:"not null;default:false" json:"email_verified"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
 type Session struct {
-	Id                    uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	UserId                uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	RefreshToken          string    `gorm:"type:text;not null" json:"refresh_token"`
-	UserAgent             string    `gorm:"type:text" json:"user_agent"`
-	IPAddress             string    `gorm:"type:text" json:"ip_address"`
-	RefreshTokenExpiresAt time.Time `gorm:"type:timestamptz;not null" json:"refresh_token_expires_at"`
-	CreatedAt             time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt             time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	Id           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserId       uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	RefreshToken string    `gorm:"type:text;not null" json:"refresh_token"`
+	UserAgent    string    `gorm:"type:text" json:"user_agent"`
+	IPAddress    string    `gorm:"type:text" json:"ip_address"`
+	Device       string    `gorm:"type:text" json:"device"`
+	IsBlocked    bool      `gorm:"default:false" json:"is_blocked"`
+	// ClientId và Scope chỉ có ở session phát hành cho OAuth client qua /v1/oauth/token
+	ClientId string `gorm:"type:text" json:"client_id"`
+	Scope    string `gorm:"type:text" json:"scope"`
+	// SSOSid là sid của phiên đăng nhập trình duyệt (cookie oauth2_sso) đã cấp session này
+	SSOSid                string     `gorm:"column:sso_sid;type:text" json:"sso_sid"`
+	RefreshTokenExpiresAt time.Time  `gorm:"type:timestamptz;not null" json:"refresh_token_expires_at"`
+	LastUsedAt            *time.Time `gorm:"type:timestamptz" json:"last_used_at"`
+	RevokedAt             *time.Time `gorm:"type:timestamptz" json:"revoked_at"`
+	CreatedAt             time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt             time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (Session) TableSession() string {
 	return "session"
 }
+
+const (
+	LinkDecisionAutoLinked            = "auto_linked"
+	LinkDecisionConfirmationRequested = "confirmation_requested"
+	LinkDecisionConfirmed             = "confirmed"
+	LinkDecisionSkippedAmbiguous      = "skipped_ambiguous"
+	LinkDecisionLinked                = "linked"
+	LinkDecisionUnlinked              = "unlinked"
+)
+
+// AccountLinkAudit ghi lại quyết định liên kết identity vào user
+type AccountLinkAudit struct {
+	Id         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserId     *uuid.UUID `gorm:"type:uuid" json:"user_id"`
+	Provider   string     `gorm:"type:text;not null" json:"provider"`
+	ProviderId string     `gorm:"type:text;not null" json:"provider_id"`
+	Email      string     `gorm:"type:text" json:"email"`
+	Decision   string     `gorm:"type:text;not null" json:"decision"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// OAuthState lưu trong redis khi bắt đầu login, dùng một lần ở callback
+type OAuthState struct {
+	Provider     string    `json:"provider"`
+	Binding      string    `json:"binding"`
+	CodeVerifier string    `json:"code_verifier"`
+	Nonce        string    `json:"nonce,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	// LinkUserId khác nil khi login để liên kết thêm provider cho user đang đăng nhập
+	LinkUserId *uuid.UUID `json:"link_user_id,omitempty"`
+	// AuthorizationRequestId khác rỗng khi login là bước xác thực user của /v1/oauth/authorize
+	AuthorizationRequestId string `json:"authorization_request_id,omitempty"`
+}
+
+// PendingLink lưu trong redis khi login cần user xác nhận trước khi liên kết vào tài khoản có sẵn
+type PendingLink struct {
+	UserId        uuid.UUID `json:"user_id"`
+	Provider      string    `json:"provider"`
+	ProviderId    string    `json:"provider_id"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuthClient là client được phép gọi các endpoint của authorization server (/v1/oauth/*)
+type OAuthClient struct {
+	Id               uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	ClientId         string    `gorm:"type:text;not null;unique" json:"client_id"`
+	ClientSecretHash string    `gorm:"type:text;not null" json:"-"`
+	Name             string    `gorm:"type:text;not null" json:"name"`
+	// RedirectUris và Scopes lưu dạng danh sách phân cách bằng khoảng trắng như tham số scope của OAuth
+	RedirectUris string `gorm:"type:text;not null;default:''" json:"redirect_uris"`
+	Scopes       string `gorm:"type:text;not null;default:''" json:"scopes"`
+	// IsPublic là client không giữ được secret (SPA, mobile), chỉ xác thực bằng PKCE
+	IsPublic bool `gorm:"not null;default:false" json:"is_public"`
+	// FirstParty là app của chính mình, user không cần qua màn hình đồng ý
+	FirstParty bool   `gorm:"not null;default:false" json:"first_party"`
+	GrantTypes string `gorm:"type:text;not null;default:'authorization_code refresh_token'" json:"grant_types"`
+	// Jwks là JWK Set công khai (JSON) để verify request object đã ký (RFC 9101)
+	Jwks                               string `gorm:"type:text;not null;default:''" json:"jwks"`
+	RequirePushedAuthorizationRequests bool   `gorm:"not null;default:false" json:"require_pushed_authorization_requests"`
+	RequireSignedRequestObject         bool   `gorm:"not null;default:false" json:"require_signed_request_object"`
+	// URI cho OIDC logout: PostLogoutRedirectUris phân cách bằng khoảng trắng như RedirectUris
+	PostLogoutRedirectUris string `gorm:"type:text;not null;default:''" json:"post_logout_redirect_uris"`
+	BackchannelLogoutUri   string `gorm:"type:text;not null;default:''" json:"backchannel_logout_uri"`
+	FrontchannelLogoutUri  string `gorm:"type:text;not null;default:''" json:"frontchannel_logout_uri"`
+	// TokenExchangeAudiences là audience client được xin khi đổi token (RFC 8693), phân cách bằng khoảng trắng
+	TokenExchangeAudiences string `gorm:"type:text;not null;default:''" json:"token_exchange_audiences"`
+	// thời hạn token riêng của client (phút / giờ), 0 là dùng giá trị mặc định của server
+	AccessTokenTimeLife  uint16     `gorm:"not null;default:0" json:"access_token_time_life"`
+	RefreshTokenTimeLife uint16     `gorm:"not null;default:0" json:"refresh_token_time_life"`
+	DisabledAt           *time.Time `json:"disabled_at"`
+	CreatedAt            time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt            time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// UserConsent là các scope user đã đồng ý cấp cho một client, không hỏi lại khi client
+// xin scope nằm trong danh sách này
+type UserConsent struct {
+	Id        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserId    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	ClientId  string    `gorm:"type:text;not null" json:"client_id"`
+	Scope     string    `gorm:"type:text;not null;default:''" json:"scope"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
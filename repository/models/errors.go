@@ -0,0 +1,5 @@
+package models
+
+import "errors"
+
+var ErrLastIdentity = errors.New("cannot remove the last linked identity")
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuthorizationRequest là request /v1/oauth/authorize đã được kiểm tra, lưu trong redis
+// trong lúc user đăng nhập qua upstream provider
+type AuthorizationRequest struct {
+	ClientId    string `json:"client_id"`
+	RedirectUri string `json:"redirect_uri"`
+	// RedirectUriProvided: redirect_uri có trong request (không phải mặc định của client),
+	// khi đó token request bắt buộc gửi lại đúng giá trị này (RFC 6749 mục 4.1.3)
+	RedirectUriProvided bool   `json:"redirect_uri_provided,omitempty"`
+	Scope               string `json:"scope"`
+	State               string `json:"state,omitempty"`
+	Nonce               string `json:"nonce,omitempty"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Prompt              string `json:"prompt,omitempty"`
+	// DeviceUserCode khác rỗng khi user đăng nhập để duyệt device code (RFC 8628):
+	// login xong thì quay lại trang /v1/oauth/device thay vì cấp code cho client
+	DeviceUserCode string `json:"device_user_code,omitempty"`
+	// ConsentSid, ConsentToken gắn request với SSO session đã xem màn hình đồng ý và
+	// token chống CSRF trong form, POST /v1/oauth/consent phải khớp cả hai
+	ConsentSid   string    `json:"consent_sid,omitempty"`
+	ConsentToken string    `json:"consent_token,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AuthorizationCode lưu trong redis, đổi lấy token đúng một lần ở /v1/oauth/token
+type AuthorizationCode struct {
+	ClientId            string    `json:"client_id"`
+	RedirectUri         string    `json:"redirect_uri"`
+	RedirectUriProvided bool      `json:"redirect_uri_provided,omitempty"`
+	Scope               string    `json:"scope"`
+	Nonce               string    `json:"nonce,omitempty"`
+	CodeChallenge       string    `json:"code_challenge"`
+	CodeChallengeMethod string    `json:"code_challenge_method"`
+	UserId              uuid.UUID `json:"user_id"`
+	AuthTime            time.Time `json:"auth_time"`
+	SSOSid              string    `json:"sso_sid,omitempty"`
+	// thông tin trình duyệt lúc user đồng ý, dùng cho session tạo ở /v1/oauth/token
+	UserAgent string    `json:"user_agent,omitempty"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SSOSession là phiên đăng nhập của trình duyệt ở authorization server (cookie oauth2_sso),
+// cho phép /v1/oauth/authorize cấp code mà không bắt user đăng nhập lại
+type SSOSession struct {
+	// Sid là id công khai của phiên, khác giá trị cookie, được gắn vào các session cấp cho client
+	Sid       string    `json:"sid,omitempty"`
+	UserId    uuid.UUID `json:"user_id"`
+	AuthTime  time.Time `json:"auth_time"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Trạng thái của device authorization
+const (
+	DeviceStatusPending  = "pending"
+	DeviceStatusApproved = "approved"
+	DeviceStatusDenied   = "denied"
+)
+
+// DeviceAuthorization là yêu cầu của device authorization grant (RFC 8628), lưu trong redis
+// theo device_code cho đến khi thiết bị đổi được token hoặc hết hạn
+type DeviceAuthorization struct {
+	ClientId string `json:"client_id"`
+	Scope    string `json:"scope"`
+	UserCode string `json:"user_code"`
+	Status   string `json:"status"`
+	// UserId, AuthTime và thông tin trình duyệt có sau khi user duyệt
+	UserId    uuid.UUID `json:"user_id,omitempty"`
+	AuthTime  time.Time `json:"auth_time,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackchannelLogoutJob là một lần gửi logout token tới backchannel_logout_uri của client,
+// nằm trong hàng đợi redis cho tới khi client nhận hoặc hết số lần thử
+type BackchannelLogoutJob struct {
+	Id        string    `json:"id"`
+	ClientId  string    `json:"client_id"`
+	UserId    uuid.UUID `json:"user_id"`
+	SessionId uuid.UUID `json:"session_id"`
+	Attempt   int       `json:"attempt"`
+	CreatedAt time.Time `json:"created_at"`
+	// Member là phần tử gốc trong hàng đợi, dùng để xoá đúng job sau khi xử lý xong
+	Member string `json:"-"`
+}
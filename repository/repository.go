@@ -20,6 +20,10 @@ func (r repository) Auth() interfaces.Auth {
 	return impl.NewAuth(r.db)
 }
 
+func (r repository) Client() interfaces.Client {
+	return impl.NewClient(r.db)
+}
+
 func (r repository) Redis() interfaces.Redis {
 	return impl.NewRedis(r.dbRedis)
 }
@@ -2,18 +2,20 @@ package github
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/service/provider"
 	utils "github.com/johnquangdev/oauth2/utils"
 	"golang.org/x/oauth2"
 )
 
+const ProviderName = "github"
+
 type Oauth2GithubService struct {
 	repo   interfaces.Repo
 	config oauth2.Config
@@ -39,37 +41,48 @@ func NewGithubOauth2Service(repo interfaces.Repo, cfg utils.Config) (*Oauth2Gith
 	}, nil
 }
 
-func GenerateRandomState() (string, error) {
-	b := make([]byte, 16)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "", err
-	}
-	return base64.URLEncoding.EncodeToString(b), nil
+func (g *Oauth2GithubService) Name() string {
+	return ProviderName
 }
 
-func (g *Oauth2GithubService) GenerateAuthURL() (*GetAuthURLResponse, error) {
-	// Generate random state if not provided
-	state, err := GenerateRandomState()
+// AuthCodeURL tạo URL login, chỉ gửi code_challenge (S256) lên GitHub
+func (g *Oauth2GithubService) AuthCodeURL(ctx context.Context, req provider.AuthRequest) (string, error) {
+	// Use oauth2 library to generate auth URL
+	return g.config.AuthCodeURL(req.State, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(req.CodeVerifier)), nil
+}
+
+func (g *Oauth2GithubService) Exchange(ctx context.Context, code string, req provider.AuthRequest) (*oauth2.Token, error) {
+	token, err := g.config.Exchange(ctx, code, oauth2.VerifierOption(req.CodeVerifier))
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate state: %w", err)
+		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
 	}
 
-	// Use oauth2 library to generate auth URL
-	authURL := g.config.AuthCodeURL(state, oauth2.AccessTypeOffline)
-
-	return &GetAuthURLResponse{
-		Url: authURL,
-	}, nil
+	return token, nil
 }
 
-func (g *Oauth2GithubService) Exchange(ctx context.Context, code string) (string, error) {
-	token, err := g.config.Exchange(ctx, code)
+// FetchIdentity lấy profile và email đã xác minh của user từ GitHub API
+func (g *Oauth2GithubService) FetchIdentity(ctx context.Context, token *oauth2.Token, req provider.AuthRequest) (*provider.Identity, error) {
+	//get user info from github
+	userInfoGithub, err := g.GetUserInfoGithub(ctx, token.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user info from github: %w", err)
+	}
+
+	//get user email from github
+	emailInfoGithub, err := g.GetUserEmail(ctx, token.AccessToken)
 	if err != nil {
-		return "", fmt.Errorf("failed to exchange code for token: %w", err)
+		return nil, fmt.Errorf("failed to get user email from github: %w", err)
 	}
 
-	return token.AccessToken, nil
+	return &provider.Identity{
+		Provider: ProviderName,
+		// id là định danh bất biến của tài khoản GitHub (login có thể đổi)
+		Subject:       strconv.FormatInt(userInfoGithub.Id, 10),
+		Email:         emailInfoGithub,
+		EmailVerified: true,
+		Name:          userInfoGithub.Name,
+		Avatar:        userInfoGithub.AvatarURL,
+	}, nil
 }
 
 func (g *Oauth2GithubService) GetUserInfoGithub(ctx context.Context, access_token string) (*GetGithubUserInfoReply, error) {
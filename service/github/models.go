@@ -1,6 +1,7 @@
 package github
 
 type GetGithubUserInfoReply struct {
+	Id        int64  `json:"id"`
 	Provider  string `json:"provider"`
 	Login     string `json:"login"`
 	Location  string `json:"location"`
@@ -8,10 +9,6 @@ type GetGithubUserInfoReply struct {
 	Name      string `json:"name"`
 }
 
-type GetAuthURLResponse struct {
-	Url string
-}
-
 type EmailInfo struct {
 	Email      string `json:"email"`
 	Primary    bool   `json:"primary"`
@@ -2,32 +2,28 @@ package google
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 
+	"github.com/johnquangdev/oauth2/service/provider"
 	"github.com/johnquangdev/oauth2/utils"
 	configGoogle "golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/idtoken"
 )
 
-// struct implement interfaces 1
+const ProviderName = "google"
+
+// struct implement provider.Provider
 type ServiceOauthGoogle struct {
 	config *configGoogle.Config
 	cfg    utils.Config
 }
 
-// // interfaces
-type Oauth2Custom interface {
-	ChangeCodeToToken(context.Context, string) (string, error)
-	GenerateAuthURL(state string) string
-	GetUserInfoGoogle(accessToken string) (UserInfoResp, error)
-}
-
 func NewGoogleOAuthService(cfg utils.Config) (*ServiceOauthGoogle, error) {
 	// Parse scopes from config
 	scopes := []string{}
@@ -50,12 +46,81 @@ func NewGoogleOAuthService(cfg utils.Config) (*ServiceOauthGoogle, error) {
 	}, nil
 }
 
-func (s *ServiceOauthGoogle) GenerateAuthURL() (string, error) {
-	state, err := GenerateRandomState()
+func (s *ServiceOauthGoogle) Name() string {
+	return ProviderName
+}
+
+// AuthCodeURL tạo URL login, chỉ gửi code_challenge (S256) và nonce lên Google
+func (s *ServiceOauthGoogle) AuthCodeURL(ctx context.Context, req provider.AuthRequest) (string, error) {
+	return s.config.AuthCodeURL(req.State,
+		configGoogle.AccessTypeOffline,
+		configGoogle.S256ChallengeOption(req.CodeVerifier),
+		configGoogle.SetAuthURLParam("nonce", req.Nonce),
+	), nil
+}
+
+func (s *ServiceOauthGoogle) Exchange(ctx context.Context, code string, req provider.AuthRequest) (*configGoogle.Token, error) {
+	token, err := s.config.Exchange(ctx, code, configGoogle.VerifierOption(req.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange code failed by err: %w", err)
+	}
+	if !token.Valid() {
+		return nil, fmt.Errorf("invalid token received from Google")
+	}
+	return token, nil
+}
+
+// FetchIdentity xác thực id_token rồi lấy thêm profile từ userinfo endpoint
+func (s *ServiceOauthGoogle) FetchIdentity(ctx context.Context, token *configGoogle.Token, req provider.AuthRequest) (*provider.Identity, error) {
+	idToken, ok := token.Extra("id_token").(string)
+	if !ok || idToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+
+	//Verify ID Token (xác thực danh tính)
+	// idtoken.Validate tự động verify với Google's public keys và kiểm tra audience
+	payload, err := idtoken.Validate(ctx, idToken, s.cfg.ClientId_Google)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %v", err)
+	}
+
+	// Verify issuer
+	if payload.Issuer != "https://accounts.google.com" && payload.Issuer != "accounts.google.com" {
+		return nil, fmt.Errorf("invalid issuer: %s", payload.Issuer)
+	}
+
+	// Verify nonce
+	nonce, _ := payload.Claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(nonce), []byte(req.Nonce)) != 1 {
+		return nil, fmt.Errorf("invalid id_token: nonce mismatch")
+	}
+
+	// Verify email_verified
+	emailVerified, ok := payload.Claims["email_verified"].(bool)
+	if !ok || !emailVerified {
+		return nil, fmt.Errorf("email not verified")
+	}
+
+	// Verify email exists
+	email, ok := payload.Claims["email"].(string)
+	if !ok || email == "" {
+		return nil, fmt.Errorf("email claim missing")
+	}
+
+	//Get userInfoGoogle
+	userInfoGoogle, err := s.GetUserInfoGoogle(token.AccessToken)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return s.config.AuthCodeURL(state, configGoogle.AccessTypeOffline), nil
+
+	return &provider.Identity{
+		Provider:      ProviderName,
+		Subject:       payload.Subject,
+		Email:         email,
+		EmailVerified: emailVerified,
+		Name:          userInfoGoogle.Name,
+		Avatar:        userInfoGoogle.Picture,
+	}, nil
 }
 
 func (s *ServiceOauthGoogle) GetUserInfoGoogle(accessToken string) (*UserInfoResp, error) {
@@ -82,27 +147,6 @@ func (s *ServiceOauthGoogle) GetUserInfoGoogle(accessToken string) (*UserInfoRes
 	if err := json.NewDecoder(resp.Body).Decode(&UserInfo); err != nil {
 		return nil, fmt.Errorf("decode user info: %w", err)
 	}
-	UserInfo.Provider = "google"
+	UserInfo.Provider = ProviderName
 	return &UserInfo, nil
 }
-
-func (o ServiceOauthGoogle) ChangeCodeToToken(ctx context.Context, code string) (string, string, error) {
-	token, err := o.config.Exchange(ctx, code)
-	if err != nil {
-		return "", "", fmt.Errorf("exchange code failed by err: %w", err)
-	}
-	if !token.Valid() {
-		return "", "", fmt.Errorf("invalid token received from Google")
-	}
-	idToken := token.Extra("id_token").(string)
-	return token.AccessToken, idToken, nil
-}
-
-func GenerateRandomState() (string, error) {
-	b := make([]byte, 16)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "", err
-	}
-	return base64.URLEncoding.EncodeToString(b), nil
-}
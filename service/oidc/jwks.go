@@ -0,0 +1,174 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	jwksCacheTimeLife   = time.Hour
+	jwksMinRefreshDelay = time.Minute
+)
+
+// keySet cache JWKS của provider, tải lại khi hết hạn hoặc gặp kid chưa biết
+type keySet struct {
+	uri    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+func newKeySet(uri string, client *http.Client) *keySet {
+	return &keySet{
+		uri:    uri,
+		client: client,
+	}
+}
+
+func (k *keySet) getKey(ctx context.Context, kid string) (interface{}, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	cached, found := k.lookup(kid)
+	if found && time.Since(k.fetchedAt) < jwksCacheTimeLife {
+		return cached, nil
+	}
+	// kid chưa biết có thể do provider vừa rotate key, nhưng không tải lại quá thường xuyên
+	if time.Since(k.fetchedAt) >= jwksMinRefreshDelay {
+		if err := k.refresh(ctx); err != nil {
+			if found {
+				return cached, nil
+			}
+			return nil, err
+		}
+	}
+	key, ok := k.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found in jwks", kid)
+	}
+	return key, nil
+}
+
+// lookup tìm key theo kid, token không có kid chỉ hợp lệ khi JWKS có đúng một key
+func (k *keySet) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+func (k *keySet) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.uri, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create jwks request: %w", err)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("jwks endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var set JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := ParsePublicKey(jwk)
+		if err != nil {
+			// bỏ qua key không hỗ trợ thay vì làm hỏng cả JWKS
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	k.keys = keys
+	k.fetchedAt = time.Now()
+	return nil
+}
+
+// ParsePublicKey chuyển một JWK (RSA, EC, OKP/Ed25519) thành public key của crypto
+func ParsePublicKey(jwk JSONWebKey) (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsa modulus: %w", err)
+		}
+		e, err := decodeBase64URL(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsa exponent: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported ec curve: %s", jwk.Crv)
+		}
+		x, err := decodeBase64URL(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ec x: %w", err)
+		}
+		y, err := decodeBase64URL(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ec y: %w", err)
+		}
+		key := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("ec point is not on curve %s", jwk.Crv)
+		}
+		return key, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported okp curve: %s", jwk.Crv)
+		}
+		x, err := decodeBase64URL(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+}
+
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(value)
+}
@@ -0,0 +1,289 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnquangdev/oauth2/service/provider"
+	"github.com/johnquangdev/oauth2/utils"
+	"golang.org/x/oauth2"
+)
+
+const idTokenLeeway = time.Minute
+
+var supportedSigningMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// Provider là một OpenID Connect provider bất kỳ, cấu hình lấy từ discovery document
+type Provider struct {
+	cfg    utils.OIDCProviderConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *Discovery
+	keys      *keySet
+}
+
+func NewProvider(cfg utils.OIDCProviderConfig) (*Provider, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("oidc provider name is required")
+	}
+	if cfg.Issuer == "" || cfg.ClientId == "" || cfg.RedirectUrl == "" {
+		return nil, fmt.Errorf("oidc provider %q requires issuer, client_id and redirect_url", cfg.Name)
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// discover tải discovery document một lần rồi cache lại, lỗi thì lần gọi sau thử lại
+func (p *Provider) discover(ctx context.Context) (*Discovery, *keySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, p.keys, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create discovery request: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("discovery endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var discovery Discovery
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode discovery document: %w", err)
+	}
+	// issuer trong discovery phải khớp issuer đã cấu hình (OIDC Discovery 4.3)
+	if strings.TrimSuffix(discovery.Issuer, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+		return nil, nil, fmt.Errorf("discovery issuer %q does not match configured issuer %q", discovery.Issuer, p.cfg.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JwksURI == "" {
+		return nil, nil, fmt.Errorf("discovery document of %q is missing required endpoints", p.cfg.Name)
+	}
+
+	p.discovery = &discovery
+	p.keys = newKeySet(discovery.JwksURI, p.client)
+	return p.discovery, p.keys, nil
+}
+
+func (p *Provider) oauth2Config(discovery *Discovery) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientId,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectUrl,
+		Scopes:       p.cfg.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}
+}
+
+// AuthCodeURL tạo URL login với state, PKCE code_challenge (S256) và nonce
+func (p *Provider) AuthCodeURL(ctx context.Context, req provider.AuthRequest) (string, error) {
+	discovery, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return p.oauth2Config(discovery).AuthCodeURL(req.State,
+		oauth2.S256ChallengeOption(req.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", req.Nonce),
+	), nil
+}
+
+func (p *Provider) Exchange(ctx context.Context, code string, req provider.AuthRequest) (*oauth2.Token, error) {
+	discovery, _, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.oauth2Config(discovery).Exchange(ctx, code, oauth2.VerifierOption(req.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange code failed by err: %w", err)
+	}
+	return token, nil
+}
+
+// FetchIdentity xác thực id_token rồi map claims (bổ sung từ userinfo endpoint nếu thiếu) sang Identity
+func (p *Provider) FetchIdentity(ctx context.Context, token *oauth2.Token, req provider.AuthRequest) (*provider.Identity, error) {
+	idToken, ok := token.Extra("id_token").(string)
+	if !ok || idToken == "" {
+		return nil, fmt.Errorf("token response of %q has no id_token", p.cfg.Name)
+	}
+	claims, err := p.VerifyIDToken(ctx, idToken, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return p.mapIdentity(ctx, token, claims)
+}
+
+// VerifyIDToken kiểm tra chữ ký (JWKS), iss, aud/azp, exp và nonce của id_token
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIdToken string, nonce string) (jwt.MapClaims, error) {
+	discovery, keys, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIdToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.getKey(ctx, kid)
+	},
+		jwt.WithValidMethods(supportedSigningMethods),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.cfg.ClientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(idTokenLeeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	// nhiều audience thì azp phải là client của mình (OIDC Core 3.1.3.7)
+	audience, _ := claims.GetAudience()
+	azp, _ := claims["azp"].(string)
+	if (len(audience) > 1 || azp != "") && azp != p.cfg.ClientId {
+		return nil, fmt.Errorf("invalid id_token: azp %q does not match client", azp)
+	}
+
+	tokenNonce, _ := claims["nonce"].(string)
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("invalid id_token: nonce mismatch")
+	}
+	return claims, nil
+}
+
+func (p *Provider) mapIdentity(ctx context.Context, token *oauth2.Token, claims jwt.MapClaims) (*provider.Identity, error) {
+	mapping := p.cfg.Claims
+	info := &provider.Identity{
+		Provider:      p.cfg.Name,
+		Subject:       claimString(claims, mapping.Subject, "sub"),
+		Email:         claimString(claims, mapping.Email, "email"),
+		EmailVerified: claimBool(claims, mapping.EmailVerified, "email_verified"),
+		Name:          claimString(claims, mapping.Name, "name"),
+		Avatar:        claimString(claims, mapping.Avatar, "picture"),
+	}
+	if info.Subject == "" {
+		return nil, fmt.Errorf("id_token of %q has no subject claim", p.cfg.Name)
+	}
+	if info.Email != "" && info.Name != "" {
+		return info, nil
+	}
+
+	// nhiều IdP (Azure AD, GitLab...) không đưa profile vào id_token
+	discovery, _, err := p.discover(ctx)
+	if err != nil || discovery.UserinfoEndpoint == "" {
+		return info, nil
+	}
+	extra, err := p.fetchUserInfo(ctx, discovery.UserinfoEndpoint, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	// sub của userinfo phải trùng với id_token (OIDC Core 5.3.2)
+	if sub := claimString(extra, "", "sub"); sub != info.Subject {
+		return nil, fmt.Errorf("userinfo subject does not match id_token")
+	}
+	if info.Email == "" {
+		info.Email = claimString(extra, mapping.Email, "email")
+		info.EmailVerified = claimBool(extra, mapping.EmailVerified, "email_verified")
+	}
+	if info.Name == "" {
+		info.Name = claimString(extra, mapping.Name, "name")
+	}
+	if info.Avatar == "" {
+		info.Avatar = claimString(extra, mapping.Avatar, "picture")
+	}
+	return info, nil
+}
+
+func (p *Provider) fetchUserInfo(ctx context.Context, endpoint string, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("userinfo endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var claims map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("decode user info: %w", err)
+	}
+	return claims, nil
+}
+
+// claimValue lấy claim theo tên, hỗ trợ claim lồng nhau dạng "a.b"
+func claimValue(claims map[string]interface{}, name string) interface{} {
+	var current interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+func claimString(claims map[string]interface{}, name string, fallback string) string {
+	if name == "" {
+		name = fallback
+	}
+	value, _ := claimValue(claims, name).(string)
+	return value
+}
+
+func claimBool(claims map[string]interface{}, name string, fallback string) bool {
+	if name == "" {
+		name = fallback
+	}
+	switch value := claimValue(claims, name).(type) {
+	case bool:
+		return value
+	case string:
+		// một số IdP trả email_verified dạng chuỗi
+		return value == "true"
+	}
+	return false
+}
@@ -0,0 +1,287 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnquangdev/oauth2/service/provider"
+	"github.com/johnquangdev/oauth2/utils"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testClientId = "test-client"
+	testKid      = "test-key"
+	testNonce    = "test-nonce"
+)
+
+// testIdP là IdP giả chạy bằng httptest: discovery, JWKS và userinfo
+type testIdP struct {
+	server   *httptest.Server
+	key      *ecdsa.PrivateKey
+	issuer   string
+	userInfo map[string]interface{}
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, Discovery{
+			Issuer:                idp.issuer,
+			AuthorizationEndpoint: idp.issuer + "/authorize",
+			TokenEndpoint:         idp.issuer + "/token",
+			UserinfoEndpoint:      idp.issuer + "/userinfo",
+			JwksURI:               idp.issuer + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, JSONWebKeySet{Keys: []JSONWebKey{{
+			Kty: "EC",
+			Kid: testKid,
+			Alg: "ES256",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}}})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, idp.userInfo)
+	})
+	idp.server = httptest.NewServer(mux)
+	idp.issuer = idp.server.URL
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// claims trả về claims hợp lệ của id_token, test sửa lại từng trường
+func (idp *testIdP) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   idp.issuer,
+		"aud":   testClientId,
+		"sub":   "user-1",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": testNonce,
+	}
+}
+
+func (idp *testIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = testKid
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (idp *testIdP) provider(t *testing.T, mapping utils.OIDCClaimMapping) *Provider {
+	t.Helper()
+	p, err := NewProvider(utils.OIDCProviderConfig{
+		Name:        "test",
+		Issuer:      idp.issuer,
+		ClientId:    testClientId,
+		RedirectUrl: "http://localhost/callback",
+		Claims:      mapping,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newTestIdP(t)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(jwt.MapClaims)
+		token   func(jwt.MapClaims) string
+		nonce   string
+		wantErr bool
+	}{
+		{name: "valid", nonce: testNonce},
+		{name: "wrong issuer", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }, nonce: testNonce, wantErr: true},
+		{name: "wrong audience", modify: func(c jwt.MapClaims) { c["aud"] = "other-client" }, nonce: testNonce, wantErr: true},
+		{name: "expired", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, nonce: testNonce, wantErr: true},
+		{name: "missing exp", modify: func(c jwt.MapClaims) { delete(c, "exp") }, nonce: testNonce, wantErr: true},
+		{name: "nonce mismatch", nonce: "other-nonce", wantErr: true},
+		{name: "empty expected nonce", modify: func(c jwt.MapClaims) { c["nonce"] = "" }, nonce: "", wantErr: true},
+		{
+			name:    "multiple audiences without azp",
+			modify:  func(c jwt.MapClaims) { c["aud"] = []string{testClientId, "other-client"} },
+			nonce:   testNonce,
+			wantErr: true,
+		},
+		{
+			name: "multiple audiences with azp",
+			modify: func(c jwt.MapClaims) {
+				c["aud"] = []string{testClientId, "other-client"}
+				c["azp"] = testClientId
+			},
+			nonce: testNonce,
+		},
+		{
+			name: "signed with unknown key",
+			token: func(c jwt.MapClaims) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodES256, c)
+				token.Header["kid"] = testKid
+				signed, _ := token.SignedString(otherKey)
+				return signed
+			},
+			nonce:   testNonce,
+			wantErr: true,
+		},
+		{
+			name: "alg none",
+			token: func(c jwt.MapClaims) string {
+				signed, _ := jwt.NewWithClaims(jwt.SigningMethodNone, c).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				return signed
+			},
+			nonce:   testNonce,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.claims()
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			raw := ""
+			if tt.token != nil {
+				raw = tt.token(claims)
+			} else {
+				raw = idp.sign(t, claims)
+			}
+			_, err := idp.provider(t, utils.OIDCClaimMapping{}).VerifyIDToken(context.Background(), raw, tt.nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyIDToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider(t, utils.OIDCClaimMapping{})
+	token := idp.sign(t, idp.claims())
+	// discovery document công bố issuer khác với issuer đã cấu hình
+	idp.issuer = "https://evil.example"
+	if _, err := p.VerifyIDToken(context.Background(), token, testNonce); err == nil {
+		t.Fatal("expected discovery issuer mismatch to be rejected")
+	}
+}
+
+func TestFetchIdentityClaimMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapping  utils.OIDCClaimMapping
+		claims   map[string]interface{}
+		userInfo map[string]interface{}
+		want     provider.Identity
+		wantErr  bool
+	}{
+		{
+			name: "standard claims",
+			claims: map[string]interface{}{
+				"email": "a@example.com", "email_verified": true, "name": "Alice", "picture": "https://img/a",
+			},
+			want: provider.Identity{Subject: "user-1", Email: "a@example.com", EmailVerified: true, Name: "Alice", Avatar: "https://img/a"},
+		},
+		{
+			name:    "custom and nested claims",
+			mapping: utils.OIDCClaimMapping{Subject: "oid", Email: "upn", EmailVerified: "verified", Name: "profile.display_name"},
+			claims: map[string]interface{}{
+				"oid": "object-1", "upn": "b@example.com", "verified": "true",
+				"profile": map[string]interface{}{"display_name": "Bob"},
+			},
+			want: provider.Identity{Subject: "object-1", Email: "b@example.com", EmailVerified: true, Name: "Bob"},
+		},
+		{
+			name:     "missing profile is read from userinfo",
+			userInfo: map[string]interface{}{"sub": "user-1", "email": "c@example.com", "email_verified": true, "name": "Carol"},
+			want:     provider.Identity{Subject: "user-1", Email: "c@example.com", EmailVerified: true, Name: "Carol"},
+		},
+		{
+			name:     "userinfo subject mismatch",
+			userInfo: map[string]interface{}{"sub": "someone-else", "email": "d@example.com", "name": "Dave"},
+			wantErr:  true,
+		},
+		{
+			name:    "missing subject",
+			mapping: utils.OIDCClaimMapping{Subject: "oid"},
+			claims:  map[string]interface{}{"email": "e@example.com", "name": "Eve"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newTestIdP(t)
+			idp.userInfo = tt.userInfo
+			claims := idp.claims()
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+			token := (&oauth2.Token{AccessToken: "test-access-token"}).WithExtra(map[string]interface{}{
+				"id_token": idp.sign(t, claims),
+			})
+			got, err := idp.provider(t, tt.mapping).FetchIdentity(context.Background(), token, provider.AuthRequest{Nonce: testNonce})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchIdentity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			tt.want.Provider = "test"
+			if *got != tt.want {
+				t.Fatalf("FetchIdentity() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePublicKeyRejectsInvalidKeys(t *testing.T) {
+	tests := []JSONWebKey{
+		{Kty: "oct"},
+		{Kty: "EC", Crv: "P-256", X: "AQ", Y: "AQ"},
+		{Kty: "EC", Crv: "secp256k1", X: "AQ", Y: "AQ"},
+		{Kty: "OKP", Crv: "Ed25519", X: "AQ"},
+		{Kty: "RSA", N: "!!", E: "AQAB"},
+	}
+	for _, jwk := range tests {
+		if _, err := ParsePublicKey(jwk); err == nil {
+			t.Errorf("ParsePublicKey(%s %s) expected error", jwk.Kty, jwk.Crv)
+		}
+	}
+}
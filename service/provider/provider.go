@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// Identity là thông tin user do provider xác nhận sau khi đăng nhập
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Avatar        string
+}
+
+// AuthRequest là các giá trị sinh ra khi bắt đầu login và lưu cùng state đến lúc callback
+type AuthRequest struct {
+	State        string
+	CodeVerifier string
+	Nonce        string
+}
+
+// Provider là một upstream OAuth2/OIDC provider (Google, GitHub, OIDC...)
+type Provider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, req AuthRequest) (string, error)
+	Exchange(ctx context.Context, code string, req AuthRequest) (*oauth2.Token, error)
+	FetchIdentity(ctx context.Context, token *oauth2.Token, req AuthRequest) (*Identity, error)
+}
+
+// Registry giữ các provider theo tên, dùng cho route /v1/auth/:provider/...
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: make(map[string]Provider),
+	}
+}
+
+func (r *Registry) Register(p Provider) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p.Name() == "" {
+		return fmt.Errorf("provider name is required")
+	}
+	if _, ok := r.providers[p.Name()]; ok {
+		return fmt.Errorf("provider %q already registered", p.Name())
+	}
+	r.providers[p.Name()] = p
+	return nil
+}
+
+func (r *Registry) Get(name string) (Provider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
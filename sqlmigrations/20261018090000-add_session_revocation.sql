@@ -0,0 +1,11 @@
+-- +migrate Up
+/*
+revoked_at đánh dấu session (cả family refresh token) đã bị thu hồi,
+ví dụ khi phát hiện refresh token cũ bị dùng lại sau khi đã rotate.
+*/
+ALTER TABLE sessions
+    ADD COLUMN revoked_at TIMESTAMPTZ;
+
+-- +migrate Down
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS revoked_at;
@@ -0,0 +1,61 @@
+-- +migrate Up
+/*
+Một user có thể đăng nhập bằng nhiều provider (Google, GitHub, OIDC...).
+Cặp provider/provider_id chuyển từ bảng users sang bảng user_identities.
+*/
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    provider_id TEXT NOT NULL,
+    email TEXT,
+    email_verified BOOL NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_user_identities_provider_provider_id
+    ON user_identities(provider, provider_id);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+-- Google và GitHub trước đây chỉ chấp nhận email đã xác minh
+INSERT INTO user_identities (user_id, provider, provider_id, email, email_verified, created_at, updated_at)
+SELECT id, provider, provider_id, email, provider IN ('google', 'github'), created_at, updated_at
+FROM users;
+
+DROP INDEX IF EXISTS idx_users_provider_provider_id;
+
+ALTER TABLE users
+    DROP COLUMN provider,
+    DROP COLUMN provider_id;
+
+-- +migrate Down
+ALTER TABLE users
+    ADD COLUMN provider TEXT,
+    ADD COLUMN provider_id TEXT;
+
+-- Lấy identity được liên kết đầu tiên của mỗi user
+UPDATE users u
+SET provider = i.provider,
+    provider_id = i.provider_id
+FROM (
+    SELECT DISTINCT ON (user_id) user_id, provider, provider_id
+    FROM user_identities
+    ORDER BY user_id, created_at
+) i
+WHERE i.user_id = u.id;
+
+UPDATE users
+SET provider = 'unknown',
+    provider_id = id::text
+WHERE provider IS NULL;
+
+ALTER TABLE users
+    ALTER COLUMN provider SET NOT NULL,
+    ALTER COLUMN provider_id SET NOT NULL;
+
+CREATE UNIQUE INDEX idx_users_provider_provider_id
+  ON users(provider, provider_id);
+
+DROP TABLE IF EXISTS user_identities;
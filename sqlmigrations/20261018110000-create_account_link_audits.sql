@@ -0,0 +1,29 @@
+-- +migrate Up
+/*
+Lưu lại mọi quyết định liên kết identity vào user
+(tự động theo email đã xác minh, chờ xác nhận, liên kết/gỡ thủ công).
+*/
+CREATE TABLE account_link_audits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    provider TEXT NOT NULL,
+    provider_id TEXT NOT NULL,
+    email TEXT,
+    decision TEXT NOT NULL CHECK (decision IN (
+        'auto_linked',
+        'confirmation_requested',
+        'confirmed',
+        'skipped_ambiguous',
+        'linked',
+        'unlinked'
+    )),
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_account_link_audits_user_id ON account_link_audits(user_id);
+
+CREATE INDEX idx_user_identities_email ON user_identities(lower(email)) WHERE email_verified;
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_user_identities_email;
+DROP TABLE IF EXISTS account_link_audits;
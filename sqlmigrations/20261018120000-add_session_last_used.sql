@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE sessions
+    ADD COLUMN last_used_at TIMESTAMPTZ;
+
+-- +migrate Down
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS last_used_at;
@@ -0,0 +1,11 @@
+-- +migrate Up
+/*
+device là tên thiết bị đọc được rút ra từ user agent lúc tạo session.
+IF NOT EXISTS vì bản cũ của migration add_session_last_used đã thêm cột này.
+*/
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS device TEXT;
+
+-- +migrate Down
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS device;
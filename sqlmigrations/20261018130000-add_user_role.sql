@@ -0,0 +1,8 @@
+-- +migrate Up
+-- role dùng cho các API quản trị (block/unblock user), admin được cấp trực tiếp trong DB
+ALTER TABLE users
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin'));
+
+-- +migrate Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS role;
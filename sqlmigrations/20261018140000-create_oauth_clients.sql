@@ -0,0 +1,13 @@
+-- +migrate Up
+-- client của authorization server (service nội bộ, app bên thứ ba), secret chỉ lưu bcrypt hash
+CREATE TABLE oauth_clients (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    client_id TEXT NOT NULL UNIQUE,
+    client_secret_hash TEXT NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS oauth_clients;
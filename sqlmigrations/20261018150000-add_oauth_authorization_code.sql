@@ -0,0 +1,21 @@
+-- +migrate Up
+-- client dùng authorization code: redirect URI cho phép, scope được cấp và client public (SPA/mobile, không có secret)
+ALTER TABLE oauth_clients
+    ADD COLUMN redirect_uris TEXT NOT NULL DEFAULT '',
+    ADD COLUMN scopes TEXT NOT NULL DEFAULT '',
+    ADD COLUMN is_public BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- session phát hành cho OAuth client, rỗng với session của first-party login
+ALTER TABLE sessions
+    ADD COLUMN client_id TEXT,
+    ADD COLUMN scope TEXT;
+
+-- +migrate Down
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS scope,
+    DROP COLUMN IF EXISTS client_id;
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS is_public,
+    DROP COLUMN IF EXISTS scopes,
+    DROP COLUMN IF EXISTS redirect_uris;
@@ -0,0 +1,20 @@
+-- +migrate Up
+-- grant_types: danh sách grant type phân cách bằng khoảng trắng
+-- access/refresh_token_time_life: 0 là dùng giá trị mặc định của server
+ALTER TABLE oauth_clients
+    ADD COLUMN grant_types TEXT NOT NULL DEFAULT 'authorization_code refresh_token',
+    ADD COLUMN access_token_time_life INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN refresh_token_time_life INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN disabled_at TIMESTAMPTZ;
+
+-- thu hồi session theo client khi client bị vô hiệu hoá
+CREATE INDEX idx_sessions_client_id ON sessions (client_id) WHERE client_id <> '';
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_sessions_client_id;
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS disabled_at,
+    DROP COLUMN IF EXISTS refresh_token_time_life,
+    DROP COLUMN IF EXISTS access_token_time_life,
+    DROP COLUMN IF EXISTS grant_types;
@@ -0,0 +1,27 @@
+-- +migrate Up
+-- scope user đã đồng ý cấp cho client, mỗi cặp user/client một dòng
+CREATE TABLE user_consents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    scope TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_user_consents_user_id_client_id ON user_consents(user_id, client_id);
+
+-- client bên thứ nhất (app của chính mình) bỏ qua màn hình đồng ý
+ALTER TABLE oauth_clients
+    ADD COLUMN first_party BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- thu hồi session khi user gỡ quyền của một app
+CREATE INDEX idx_sessions_user_id_client_id ON sessions (user_id, client_id) WHERE client_id <> '';
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_sessions_user_id_client_id;
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS first_party;
+
+DROP TABLE IF EXISTS user_consents;
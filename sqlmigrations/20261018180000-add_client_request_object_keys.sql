@@ -0,0 +1,13 @@
+-- +migrate Up
+-- jwks: JWK Set công khai của client (JSON) để verify request object (RFC 9101)
+-- require_*: client chỉ được gửi authorization request qua PAR (RFC 9126) / request object đã ký
+ALTER TABLE oauth_clients
+    ADD COLUMN jwks TEXT NOT NULL DEFAULT '',
+    ADD COLUMN require_pushed_authorization_requests BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN require_signed_request_object BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +migrate Down
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS require_signed_request_object,
+    DROP COLUMN IF EXISTS require_pushed_authorization_requests,
+    DROP COLUMN IF EXISTS jwks;
@@ -0,0 +1,23 @@
+-- +migrate Up
+-- URI của client cho OIDC RP-initiated, back-channel và front-channel logout
+ALTER TABLE oauth_clients
+    ADD COLUMN post_logout_redirect_uris TEXT NOT NULL DEFAULT '',
+    ADD COLUMN backchannel_logout_uri TEXT NOT NULL DEFAULT '',
+    ADD COLUMN frontchannel_logout_uri TEXT NOT NULL DEFAULT '';
+
+-- sso_sid: phiên đăng nhập của trình duyệt đã tạo session, để end_session đăng xuất mọi app của trình duyệt đó
+ALTER TABLE sessions
+    ADD COLUMN sso_sid TEXT;
+
+CREATE INDEX idx_sessions_sso_sid ON sessions (sso_sid) WHERE sso_sid <> '';
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_sessions_sso_sid;
+
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS sso_sid;
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS frontchannel_logout_uri,
+    DROP COLUMN IF EXISTS backchannel_logout_uri,
+    DROP COLUMN IF EXISTS post_logout_redirect_uris;
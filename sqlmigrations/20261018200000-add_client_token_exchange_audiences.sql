@@ -0,0 +1,8 @@
+-- +migrate Up
+-- token_exchange_audiences: các audience client được đổi token sang (RFC 8693), phân cách bằng khoảng trắng
+ALTER TABLE oauth_clients
+    ADD COLUMN token_exchange_audiences TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS token_exchange_audiences;
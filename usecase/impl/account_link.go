@@ -0,0 +1,138 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
+	"github.com/johnquangdev/oauth2/service/provider"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"gorm.io/gorm"
+)
+
+// mergeByEmail áp dụng ACCOUNT_LINKING_POLICY cho identity chưa liên kết với user nào.
+// Trả về user có sẵn nếu đã tự động liên kết, hoặc PendingLink nếu cần user xác nhận.
+func (o *OAuth2Impl) mergeByEmail(ctx context.Context, identity *provider.Identity) (*models.User, *uModels.PendingLink, error) {
+	policy := o.cfg.AccountLinkingPolicy
+	if policy != uModels.LinkingPolicyAuto && policy != uModels.LinkingPolicyConfirm {
+		return nil, nil, nil
+	}
+	// chỉ tin email mà provider đã xác minh
+	if !identity.EmailVerified || identity.Email == "" {
+		return nil, nil, nil
+	}
+
+	users, err := o.repo.Auth().FindUsersByVerifiedEmail(ctx, identity.Email)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case len(users) == 0:
+		return nil, nil, nil
+	case len(users) > 1:
+		// nhiều tài khoản cùng email thì không đoán, tạo user mới như bình thường
+		err := recordLinkAudit(ctx, o.repo, nil, identity.Provider, identity.Subject, identity.Email, models.LinkDecisionSkippedAmbiguous)
+		return nil, nil, err
+	}
+	user := &users[0]
+
+	if policy == uModels.LinkingPolicyAuto {
+		userIdentity := newUserIdentity(identity)
+		userIdentity.UserId = user.Id
+		if err := o.repo.Auth().CreateIdentity(ctx, userIdentity); err != nil {
+			return nil, nil, fmt.Errorf("create identity error: %w", err)
+		}
+		if err := recordLinkAudit(ctx, o.repo, &user.Id, identity.Provider, identity.Subject, identity.Email, models.LinkDecisionAutoLinked); err != nil {
+			return nil, nil, err
+		}
+		return user, nil, nil
+	}
+
+	// policy confirm: user phải đăng nhập tài khoản có sẵn rồi xác nhận bằng link token
+	token, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, nil, err
+	}
+	ttl := time.Duration(o.cfg.AccountLinkTimeLife) * time.Minute
+	err = o.repo.Redis().SavePendingLink(ctx, token, &models.PendingLink{
+		UserId:        user.Id,
+		Provider:      identity.Provider,
+		ProviderId:    identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		CreatedAt:     time.Now().UTC(),
+	}, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := recordLinkAudit(ctx, o.repo, &user.Id, identity.Provider, identity.Subject, identity.Email, models.LinkDecisionConfirmationRequested); err != nil {
+		return nil, nil, err
+	}
+	return nil, &uModels.PendingLink{
+		LinkToken: token,
+		Provider:  identity.Provider,
+		Email:     identity.Email,
+		ExpiresIn: ttl,
+	}, nil
+}
+
+// ConfirmLink liên kết identity đang chờ vào user hiện tại, chỉ đúng user có email trùng mới xác nhận được
+func (o *OAuth2Impl) ConfirmLink(ctx context.Context, userId uuid.UUID, linkToken string) (*uModels.Identity, error) {
+	pending, err := o.repo.Redis().ConsumePendingLink(ctx, linkToken)
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil || pending.UserId != userId {
+		return nil, uModels.ErrInvalidLinkToken
+	}
+
+	// identity có thể đã được liên kết ở nơi khác trong lúc chờ xác nhận
+	existing, err := o.repo.Auth().GetIdentityByProviderAndProviderId(ctx, pending.Provider, pending.ProviderId)
+	if err == nil {
+		if existing.UserId != userId {
+			return nil, uModels.ErrIdentityLinked
+		}
+		linked := toIdentity(*existing)
+		return &linked, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	userIdentity := &models.UserIdentity{
+		Id:            uuid.New(),
+		UserId:        userId,
+		Provider:      pending.Provider,
+		ProviderId:    pending.ProviderId,
+		Email:         pending.Email,
+		EmailVerified: pending.EmailVerified,
+	}
+	if err := o.repo.Auth().CreateIdentity(ctx, userIdentity); err != nil {
+		return nil, fmt.Errorf("create identity error: %w", err)
+	}
+	if err := recordLinkAudit(ctx, o.repo, &userId, pending.Provider, pending.ProviderId, pending.Email, models.LinkDecisionConfirmed); err != nil {
+		return nil, err
+	}
+	linked := toIdentity(*userIdentity)
+	return &linked, nil
+}
+
+func recordLinkAudit(ctx context.Context, repo rInterfaces.Repo, userId *uuid.UUID, provider string, providerId string, email string, decision string) error {
+	err := repo.Auth().CreateLinkAudit(ctx, &models.AccountLinkAudit{
+		Id:         uuid.New(),
+		UserId:     userId,
+		Provider:   provider,
+		ProviderId: providerId,
+		Email:      email,
+		Decision:   decision,
+	})
+	if err != nil {
+		return fmt.Errorf("create link audit error: %w", err)
+	}
+	return nil
+}
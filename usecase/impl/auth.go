@@ -2,21 +2,27 @@ package impl
 
 import (
 	"context"
-	"time"
+	"errors"
+	"fmt"
 
 	"github.com/google/uuid"
 	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	rModels "github.com/johnquangdev/oauth2/repository/models"
 	"github.com/johnquangdev/oauth2/usecase/interfaces"
 	"github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"gorm.io/gorm"
 )
 
 type AuthImpl struct {
 	repo rInterfaces.Repo
+	cfg  utils.Config
 }
 
-func NewSystemAuth(repo rInterfaces.Repo) interfaces.SystemAuth {
+func NewSystemAuth(cfg utils.Config, repo rInterfaces.Repo) interfaces.SystemAuth {
 	return &AuthImpl{
 		repo: repo,
+		cfg:  cfg,
 	}
 }
 
@@ -27,10 +33,9 @@ func (u AuthImpl) GetUserByProviderAndProviderId(ctx context.Context, provider s
 		return nil, err
 	}
 	return &models.User{
-		Id:       user.Id,
-		Email:    user.Email,
-		Provider: user.Provider,
-		Status:   user.Status,
+		Id:     user.Id,
+		Email:  user.Email,
+		Status: user.Status,
 	}, nil
 }
 func (u AuthImpl) GetUserById(ctx context.Context, id uuid.UUID) (*models.User, error) {
@@ -38,18 +43,100 @@ func (u AuthImpl) GetUserById(ctx context.Context, id uuid.UUID) (*models.User,
 	if err != nil {
 		return nil, err
 	}
-	return &models.User{
-		Id:         user.Id,
-		Name:       user.Name,
-		Email:      user.Email,
-		Avatar:     user.Avatar,
-		Provider:   user.Provider,
-		Status:     user.Status,
-		ProviderId: user.ProviderId,
-		CreatedAt:  user.CreatedAt,
-		UpdatedAt:  user.UpdatedAt,
-	}, nil
+	identities, err := u.repo.Auth().ListIdentitiesByUserId(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toUser(user, identities), nil
+}
+
+func (u AuthImpl) ListIdentities(ctx context.Context, userId uuid.UUID) ([]models.Identity, error) {
+	identities, err := u.repo.Auth().ListIdentitiesByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return toIdentities(identities), nil
 }
-func (u AuthImpl) AddBackList(uuid.UUID, string, time.Duration) error {
-	return nil
+
+// UnlinkIdentity gỡ một provider khỏi user, không cho gỡ identity cuối cùng
+func (u AuthImpl) UnlinkIdentity(ctx context.Context, userId uuid.UUID, identityId uuid.UUID) error {
+	identity, err := u.repo.Auth().DeleteIdentity(ctx, userId, identityId)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return models.ErrIdentityNotFound
+	case errors.Is(err, rModels.ErrLastIdentity):
+		return models.ErrLastIdentity
+	case err != nil:
+		return err
+	}
+	return recordLinkAudit(ctx, u.repo, &userId, identity.Provider, identity.ProviderId, identity.Email, rModels.LinkDecisionUnlinked)
+}
+
+// Logout chỉ thu hồi session của refresh token (refresh token và access token còn hạn),
+// các session khác của user và trạng thái tài khoản không bị ảnh hưởng
+func (u AuthImpl) Logout(ctx context.Context, refreshToken string) error {
+	claims, err := utils.VerifyToken(refreshToken, u.cfg, utils.TokenTypeRefresh)
+	if err != nil || claims.SessionId == uuid.Nil {
+		return models.ErrInvalidRefreshToken
+	}
+	session, err := u.repo.Auth().GetSessionById(ctx, claims.SessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.ErrInvalidRefreshToken
+		}
+		return fmt.Errorf("get session error: %w", err)
+	}
+	if session.UserId != claims.Id {
+		return models.ErrInvalidRefreshToken
+	}
+	// logout nhiều lần vẫn trả về thành công
+	if session.RevokedAt != nil {
+		return nil
+	}
+	if err := blacklistToken(ctx, u.repo, claims.ID, claims.ExpiresAt); err != nil {
+		return err
+	}
+	return revokeSessions(ctx, u.repo, u.cfg, session.Id)
+}
+
+// RevokeToken thu hồi riêng một token (access hoặc refresh) theo jti.
+// Token không hợp lệ hoặc đã hết hạn thì không cần làm gì.
+func (u AuthImpl) RevokeToken(ctx context.Context, token string) error {
+	claims, err := utils.VerifyToken(token, u.cfg, utils.TokenTypeAccess)
+	if err != nil {
+		claims, err = utils.VerifyToken(token, u.cfg, utils.TokenTypeRefresh)
+	}
+	if err != nil {
+		return nil
+	}
+	return blacklistToken(ctx, u.repo, claims.ID, claims.ExpiresAt)
+}
+
+// BlockUser khoá tài khoản (chỉ admin) và thu hồi mọi session đang mở
+func (u AuthImpl) BlockUser(ctx context.Context, userId uuid.UUID) error {
+	if err := u.setUserStatus(ctx, userId, models.StatusBlocked); err != nil {
+		return err
+	}
+	return revokeUserSessions(ctx, u.repo, u.cfg, userId)
+}
+
+func (u AuthImpl) UnblockUser(ctx context.Context, userId uuid.UUID) error {
+	return u.setUserStatus(ctx, userId, models.StatusActive)
+}
+
+func (u AuthImpl) setUserStatus(ctx context.Context, userId uuid.UUID, status string) error {
+	err := u.repo.Auth().UpdateUserStatus(ctx, userId, status)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.ErrUserNotFound
+	}
+	return err
+}
+
+// Refresh đổi refresh token của first-party login lấy cặp access/refresh token mới
+func (u AuthImpl) Refresh(ctx context.Context, refreshToken string) (*models.TokenJwt, error) {
+	tokens, _, err := refreshSession(ctx, u.repo, u.cfg, refreshToken, "")
+	if err != nil {
+		return nil, err
+	}
+	return tokens.toTokenJwt(), nil
 }
@@ -0,0 +1,618 @@
+package impl
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	ScopeOpenId        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+
+	// user luôn đăng nhập qua upstream provider (federated, một yếu tố)
+	AmrFederated = "fed"
+	AcrFederated = "1"
+
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// userScopes là scope về user, không có ý nghĩa với token client_credentials
+var userScopes = []string{ScopeOpenId, ScopeProfile, ScopeEmail, ScopeOfflineAccess}
+
+// pkceValue là code_verifier / code_challenge hợp lệ theo RFC 7636 mục 4.1 và 4.2
+var pkceValue = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// Authorize kiểm tra request /v1/oauth/authorize. Lỗi client_id / redirect_uri / request_uri /
+// request object được trả về dưới dạng error vì không được redirect về redirect_uri chưa xác minh;
+// các lỗi còn lại được redirect về client kèm error và state.
+func (s *OAuthServerImpl) Authorize(ctx context.Context, req uModels.AuthorizeRequest) (*uModels.AuthorizeResult, error) {
+	client, err := s.authorizationClient(ctx, req.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	// request đã push qua PAR được kiểm tra từ lúc push, chỉ cần đúng client
+	if req.RequestUri != "" {
+		authzReq, err := s.consumePushedAuthorizationRequest(ctx, client, req.RequestUri)
+		if err != nil {
+			return nil, err
+		}
+		return s.startAuthorization(ctx, client, authzReq, req.SSOSessionId, req.Client)
+	}
+	signed := req.Request != ""
+	if signed {
+		if req, err = s.applyRequestObject(client, req); err != nil {
+			return nil, err
+		}
+	}
+	redirectUri, err := resolveRedirectUri(client, req.RedirectUri)
+	if err != nil {
+		return nil, err
+	}
+
+	fail := func(code string, description string) (*uModels.AuthorizeResult, error) {
+		return &uModels.AuthorizeResult{
+			RedirectURL: authorizationRedirect(s.cfg, redirectUri, url.Values{
+				"error":             {code},
+				"error_description": {description},
+			}, req.State),
+		}, nil
+	}
+	if client.RequirePushedAuthorizationRequests || s.cfg.RequirePushedAuthorizationRequests {
+		return fail("invalid_request", "pushed authorization request is required")
+	}
+	authzReq, err := s.validateAuthorizeRequest(client, redirectUri, req, signed)
+	if err != nil {
+		var oauthErr *uModels.OAuthError
+		if errors.As(err, &oauthErr) {
+			return fail(oauthErr.Code, oauthErr.Description)
+		}
+		return nil, err
+	}
+	return s.startAuthorization(ctx, client, authzReq, req.SSOSessionId, req.Client)
+}
+
+// authorizationClient lấy client theo client_id của authorization request
+func (s *OAuthServerImpl) authorizationClient(ctx context.Context, clientId string) (*models.OAuthClient, error) {
+	client, err := s.repo.Client().GetClientByClientId(ctx, clientId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, uModels.ErrUnknownClient
+		}
+		return nil, err
+	}
+	if client.DisabledAt != nil {
+		return nil, uModels.ErrUnknownClient
+	}
+	return client, nil
+}
+
+// resolveRedirectUri so khớp chính xác redirect_uri với danh sách đã đăng ký, không cho phép
+// wildcard hay prefix. Client chỉ có một redirect uri thì được bỏ trống.
+func resolveRedirectUri(client *models.OAuthClient, redirectUri string) (string, error) {
+	redirectUris := strings.Fields(client.RedirectUris)
+	if redirectUri == "" && len(redirectUris) == 1 {
+		redirectUri = redirectUris[0]
+	}
+	if !slices.Contains(redirectUris, redirectUri) {
+		return "", uModels.ErrInvalidRedirectUri
+	}
+	return redirectUri, nil
+}
+
+// validateAuthorizeRequest kiểm tra tham số sau khi redirect_uri đã được xác minh,
+// lỗi trả về là *uModels.OAuthError
+func (s *OAuthServerImpl) validateAuthorizeRequest(client *models.OAuthClient, redirectUri string, req uModels.AuthorizeRequest, signed bool) (*models.AuthorizationRequest, error) {
+	if client.RequireSignedRequestObject && !signed {
+		return nil, uModels.NewOAuthError("invalid_request", "signed request object is required")
+	}
+	if req.ResponseType != "code" {
+		return nil, uModels.NewOAuthError("unsupported_response_type", "only response_type=code is supported")
+	}
+	if !slices.Contains(strings.Fields(client.GrantTypes), GrantTypeAuthorizationCode) {
+		return nil, uModels.NewOAuthError("unauthorized_client", "client is not allowed to use the authorization code grant")
+	}
+	if req.CodeChallenge == "" {
+		return nil, uModels.NewOAuthError("invalid_request", "code_challenge is required")
+	}
+	if req.CodeChallengeMethod != "S256" {
+		return nil, uModels.NewOAuthError("invalid_request", "code_challenge_method must be S256")
+	}
+	if !pkceValue.MatchString(req.CodeChallenge) {
+		return nil, uModels.NewOAuthError("invalid_request", "code_challenge is malformed")
+	}
+	scopes := strings.Fields(req.Scope)
+	supported := strings.Fields(s.cfg.OAuthScopes)
+	for _, scope := range scopes {
+		if !slices.Contains(supported, scope) || !slices.Contains(strings.Fields(client.Scopes), scope) {
+			return nil, uModels.NewOAuthError("invalid_scope", fmt.Sprintf("scope %q is not allowed for this client", scope))
+		}
+	}
+	if req.Prompt != "" && req.Prompt != "none" && req.Prompt != "login" && req.Prompt != "consent" {
+		return nil, uModels.NewOAuthError("invalid_request", "prompt must be none, login or consent")
+	}
+	return &models.AuthorizationRequest{
+		ClientId:            client.ClientId,
+		RedirectUri:         redirectUri,
+		RedirectUriProvided: req.RedirectUri != "",
+		Scope:               strings.Join(scopes, " "),
+		State:               req.State,
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Prompt:              req.Prompt,
+		CreatedAt:           time.Now(),
+	}, nil
+}
+
+// startAuthorization cấp code luôn nếu trình duyệt đã đăng nhập ở authorization server và
+// user đã đồng ý đủ scope, ngược lại đưa user tới trang đăng nhập hoặc màn hình đồng ý
+func (s *OAuthServerImpl) startAuthorization(ctx context.Context, client *models.OAuthClient, authzReq *models.AuthorizationRequest, ssoSessionId string, browser uModels.ClientInfo) (*uModels.AuthorizeResult, error) {
+	fail := func(code string, description string) (*uModels.AuthorizeResult, error) {
+		return &uModels.AuthorizeResult{
+			RedirectURL: authorizationRedirect(s.cfg, authzReq.RedirectUri, url.Values{
+				"error":             {code},
+				"error_description": {description},
+			}, authzReq.State),
+		}, nil
+	}
+	if authzReq.Prompt != "login" {
+		sso, err := s.ssoSession(ctx, ssoSessionId)
+		if err != nil {
+			return nil, err
+		}
+		if sso != nil {
+			consent, err := needsConsent(ctx, s.repo, client, sso.UserId, authzReq.Scope, authzReq.Prompt)
+			if err != nil {
+				return nil, err
+			}
+			if consent {
+				if authzReq.Prompt == "none" {
+					return fail("consent_required", "user has not granted the requested scopes")
+				}
+				requestId, err := saveAuthorizationRequest(ctx, s.repo, s.cfg, authzReq)
+				if err != nil {
+					return nil, err
+				}
+				return &uModels.AuthorizeResult{ConsentURL: consentURL(requestId)}, nil
+			}
+			redirect, err := issueAuthorizationCode(ctx, s.repo, s.cfg, authzReq, sso, browser)
+			if err != nil {
+				return nil, err
+			}
+			return &uModels.AuthorizeResult{RedirectURL: redirect}, nil
+		}
+	}
+	if authzReq.Prompt == "none" {
+		return fail("login_required", "user is not logged in")
+	}
+
+	requestId, err := saveAuthorizationRequest(ctx, s.repo, s.cfg, authzReq)
+	if err != nil {
+		return nil, err
+	}
+	return &uModels.AuthorizeResult{
+		LoginURL: "/v1/oauth/login?" + url.Values{"request_id": {requestId}}.Encode(),
+	}, nil
+}
+
+// GetAuthorizationRequest trả về thông tin của authorization request đang chờ user đăng nhập
+func (s *OAuthServerImpl) GetAuthorizationRequest(ctx context.Context, requestId string) (*uModels.AuthorizationRequestInfo, error) {
+	authzReq, err := s.repo.Redis().GetAuthorizationRequest(ctx, requestId)
+	if err != nil {
+		return nil, err
+	}
+	if authzReq == nil {
+		return nil, uModels.ErrInvalidAuthzRequest
+	}
+	client, err := s.repo.Client().GetClientByClientId(ctx, authzReq.ClientId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, uModels.ErrInvalidAuthzRequest
+		}
+		return nil, err
+	}
+	return &uModels.AuthorizationRequestInfo{
+		Id:         requestId,
+		ClientName: client.Name,
+		Scopes:     strings.Fields(authzReq.Scope),
+	}, nil
+}
+
+// Token là endpoint /v1/oauth/token (RFC 6749 mục 4.1.3 và 6), lỗi trả về là *uModels.OAuthError
+func (s *OAuthServerImpl) Token(ctx context.Context, client *uModels.Client, req uModels.TokenRequest) (*uModels.TokenResponse, error) {
+	if slices.Contains(supportedGrantTypes, req.GrantType) && !slices.Contains(client.GrantTypes, req.GrantType) {
+		return nil, uModels.NewOAuthError("unauthorized_client", fmt.Sprintf("client is not allowed to use grant_type %s", req.GrantType))
+	}
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		return s.exchangeAuthorizationCode(ctx, client, req)
+	case GrantTypeRefreshToken:
+		if req.RefreshToken == "" {
+			return nil, uModels.NewOAuthError("invalid_request", "refresh_token is required")
+		}
+		tokens, session, err := refreshSession(ctx, s.repo, s.cfg, req.RefreshToken, client.ClientId)
+		if err != nil {
+			return nil, toInvalidGrant(err)
+		}
+		return newTokenResponse(tokens, session.Scope), nil
+	case GrantTypeClientCredentials:
+		return s.issueClientToken(ctx, client, req.Scope)
+	case GrantTypeDeviceCode:
+		return s.exchangeDeviceCode(ctx, client, req.DeviceCode)
+	case GrantTypeTokenExchange:
+		return s.exchangeToken(ctx, client, req)
+	case "":
+		return nil, uModels.NewOAuthError("invalid_request", "grant_type is required")
+	default:
+		return nil, uModels.NewOAuthError("unsupported_grant_type", "")
+	}
+}
+
+// issueClientToken cấp access token cho chính client (RFC 6749 mục 4.4): sub là client_id,
+// scope giới hạn trong scope của client và không có refresh token hay session
+func (s *OAuthServerImpl) issueClientToken(ctx context.Context, client *uModels.Client, scope string) (*uModels.TokenResponse, error) {
+	if client.IsPublic {
+		return nil, uModels.NewOAuthError("unauthorized_client", "public clients cannot use client_credentials")
+	}
+	scopes := strings.Fields(scope)
+	for _, requested := range scopes {
+		if slices.Contains(userScopes, requested) || !slices.Contains(client.Scopes, requested) {
+			return nil, uModels.NewOAuthError("invalid_scope", fmt.Sprintf("scope %q is not allowed for this client", requested))
+		}
+	}
+	// không xin scope thì cấp mọi scope của client (RFC 6749 mục 3.3)
+	if len(scopes) == 0 {
+		for _, allowed := range client.Scopes {
+			if !slices.Contains(userScopes, allowed) {
+				scopes = append(scopes, allowed)
+			}
+		}
+	}
+	granted := strings.Join(scopes, " ")
+	lifetime := clientTokenLifetime(s.cfg, client.AccessTokenTimeLife, client.RefreshTokenTimeLife)
+	accessToken, claims, err := utils.GenerateToken(s.cfg, utils.TokenParams{
+		Type:     utils.TokenTypeAccess,
+		Subject:  client.ClientId,
+		ClientId: client.ClientId,
+		Scope:    granted,
+		Audience: []string{s.cfg.JWTAudience, client.ClientId},
+		TimeLife: lifetime.access,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &uModels.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(claims.ExpiresAt.Time).Seconds()),
+		Scope:       granted,
+	}, nil
+}
+
+func (s *OAuthServerImpl) exchangeAuthorizationCode(ctx context.Context, client *uModels.Client, req uModels.TokenRequest) (*uModels.TokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, uModels.NewOAuthError("invalid_request", "code and code_verifier are required")
+	}
+	// code dùng một lần: lấy ra là xoá, kể cả khi các bước kiểm tra sau thất bại
+	code, err := s.repo.Redis().ConsumeAuthorizationCode(ctx, req.Code, authorizationCodeUsedTimeLife(s.cfg))
+	if err != nil {
+		return nil, err
+	}
+	if code == nil {
+		if err := s.revokeReplayedCode(ctx, req.Code); err != nil {
+			return nil, err
+		}
+		return nil, uModels.NewOAuthError("invalid_grant", "authorization code is invalid or expired")
+	}
+	if code.ClientId != client.ClientId {
+		return nil, uModels.NewOAuthError("invalid_grant", "authorization code is invalid or expired")
+	}
+	// redirect_uri đã gửi ở bước authorize thì phải gửi lại y hệt, không được bỏ trống
+	if (code.RedirectUriProvided || req.RedirectUri != "") && req.RedirectUri != code.RedirectUri {
+		return nil, uModels.NewOAuthError("invalid_grant", "redirect_uri does not match the authorization request")
+	}
+	if !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		return nil, uModels.NewOAuthError("invalid_grant", "code_verifier does not match code_challenge")
+	}
+
+	result, sessionId, err := s.issueUserTokens(ctx, client, userGrant{
+		userId:   code.UserId,
+		scope:    code.Scope,
+		nonce:    code.Nonce,
+		authTime: code.AuthTime,
+		ssoSid:   code.SSOSid,
+		browser: uModels.ClientInfo{
+			UserAgent: code.UserAgent,
+			IPAddress: code.IPAddress,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	replayed, err := s.repo.Redis().SetAuthorizationCodeSession(ctx, req.Code, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	// code bị gửi lại trong lúc đang đổi token: thu hồi luôn session vừa cấp
+	if replayed {
+		if err := revokeSessions(ctx, s.repo, s.cfg, sessionId); err != nil {
+			return nil, err
+		}
+		return nil, uModels.NewOAuthError("invalid_grant", "authorization code is invalid or expired")
+	}
+	return result, nil
+}
+
+// revokeReplayedCode thu hồi session đã cấp từ code đã dùng mà bị gửi lại: code có thể
+// đã lộ nên token cấp từ nó không còn tin được (RFC 6749 mục 4.1.2)
+func (s *OAuthServerImpl) revokeReplayedCode(ctx context.Context, code string) error {
+	sessionId, found, err := s.repo.Redis().ReplayAuthorizationCode(ctx, code)
+	if err != nil || !found || sessionId == uuid.Nil {
+		return err
+	}
+	return revokeSessions(ctx, s.repo, s.cfg, sessionId)
+}
+
+// authorizationCodeUsedTimeLife là thời gian giữ dấu code đã dùng, bằng thời hạn access token
+// để code gửi lại trong lúc token cấp từ nó còn dùng được vẫn bị phát hiện
+func authorizationCodeUsedTimeLife(cfg utils.Config) time.Duration {
+	return time.Duration(cfg.AccessTokenTimeLife) * time.Minute
+}
+
+// userGrant là quyền user đã cấp cho client (qua authorization code hoặc device code)
+type userGrant struct {
+	userId   uuid.UUID
+	scope    string
+	nonce    string
+	authTime time.Time
+	// sid của SSO session đã cấp quyền, rỗng với device code
+	ssoSid string
+	// trình duyệt user dùng để đăng nhập và đồng ý
+	browser uModels.ClientInfo
+}
+
+// issueUserTokens tạo session cho client và phát hành access token, refresh token khi có
+// offline_access và ID token khi có openid, trả về kèm id session vừa tạo
+func (s *OAuthServerImpl) issueUserTokens(ctx context.Context, client *uModels.Client, grant userGrant) (*uModels.TokenResponse, uuid.UUID, error) {
+	user, err := activeUser(ctx, s.repo, grant.userId)
+	if err != nil {
+		return nil, uuid.Nil, toInvalidGrant(err)
+	}
+	session := &models.Session{
+		ClientId:  client.ClientId,
+		Scope:     grant.scope,
+		SSOSid:    grant.ssoSid,
+		UserAgent: grant.browser.UserAgent,
+		IPAddress: grant.browser.IPAddress,
+		Device:    utils.ParseUserAgent(grant.browser.UserAgent).String(),
+	}
+	lifetime := clientTokenLifetime(s.cfg, client.AccessTokenTimeLife, client.RefreshTokenTimeLife)
+	tokens, err := createSession(ctx, s.repo, s.cfg, user, session, lifetime)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	scopes := strings.Fields(grant.scope)
+	// refresh token chỉ cấp khi client xin offline_access
+	if !slices.Contains(scopes, ScopeOfflineAccess) {
+		tokens.refreshToken = ""
+	}
+	result := newTokenResponse(tokens, grant.scope)
+	if slices.Contains(scopes, ScopeOpenId) {
+		result.IdToken, err = utils.GenerateIDToken(s.cfg, utils.IDTokenParams{
+			UserId:      user.Id,
+			ClientId:    client.ClientId,
+			Nonce:       grant.nonce,
+			AuthTime:    grant.authTime,
+			Amr:         []string{AmrFederated},
+			Acr:         AcrFederated,
+			SessionId:   session.Id,
+			AccessToken: tokens.accessToken,
+			TimeLife:    lifetime.access,
+		})
+		if err != nil {
+			return nil, uuid.Nil, err
+		}
+	}
+	return result, session.Id, nil
+}
+
+// saveAuthorizationRequest lưu request đã kiểm tra vào redis trong lúc chờ user đăng nhập hoặc đồng ý
+func saveAuthorizationRequest(ctx context.Context, repo rInterfaces.Repo, cfg utils.Config, authzReq *models.AuthorizationRequest) (string, error) {
+	requestId, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", fmt.Errorf("cannot generate authorization request id: %w", err)
+	}
+	// request phải sống lâu hơn state của upstream provider vì user có thể chọn provider vài lần
+	ttl := 2 * time.Duration(cfg.OAuthStateTimeLife) * time.Minute
+	if err := repo.Redis().SaveAuthorizationRequest(ctx, requestId, authzReq, ttl); err != nil {
+		return "", err
+	}
+	return requestId, nil
+}
+
+// completeAuthorization được gọi khi user đăng nhập xong qua upstream provider trong luồng
+// /v1/oauth/authorize: tạo SSO session cho trình duyệt, rồi cấp code cho client hoặc
+// chuyển sang màn hình đồng ý
+func completeAuthorization(ctx context.Context, repo rInterfaces.Repo, cfg utils.Config, requestId string, user *models.User, client uModels.ClientInfo) (*uModels.LoginResult, error) {
+	authzReq, err := repo.Redis().ConsumeAuthorizationRequest(ctx, requestId)
+	if err != nil {
+		return nil, err
+	}
+	if authzReq == nil {
+		return nil, uModels.ErrInvalidAuthzRequest
+	}
+	if user.Status == uModels.StatusBlocked || user.Status == uModels.StatusBanned {
+		return nil, uModels.ErrUserNotActive
+	}
+	authTime := time.Now()
+	ssoSessionId, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate sso session id: %w", err)
+	}
+	sid, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate sso session id: %w", err)
+	}
+	sso := &models.SSOSession{
+		Sid:       sid,
+		UserId:    user.Id,
+		AuthTime:  authTime,
+		CreatedAt: authTime,
+	}
+	err = repo.Redis().SaveSSOSession(ctx, ssoSessionId, sso, time.Duration(cfg.SSOSessionTimeLife)*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	// login để duyệt device code: quay lại trang duyệt, không cấp code
+	if authzReq.DeviceUserCode != "" {
+		return &uModels.LoginResult{
+			AuthorizationRedirect: deviceVerificationPath + "?" + url.Values{"user_code": {authzReq.DeviceUserCode}}.Encode(),
+			SSOSessionId:          ssoSessionId,
+		}, nil
+	}
+	oauthClient, err := repo.Client().GetClientByClientId(ctx, authzReq.ClientId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, uModels.ErrInvalidAuthzRequest
+		}
+		return nil, err
+	}
+	consent, err := needsConsent(ctx, repo, oauthClient, user.Id, authzReq.Scope, authzReq.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	if consent {
+		// request đã bị consume ở trên, lưu lại cho màn hình đồng ý
+		consentRequestId, err := saveAuthorizationRequest(ctx, repo, cfg, authzReq)
+		if err != nil {
+			return nil, err
+		}
+		return &uModels.LoginResult{
+			AuthorizationRedirect: consentURL(consentRequestId),
+			SSOSessionId:          ssoSessionId,
+		}, nil
+	}
+	redirect, err := issueAuthorizationCode(ctx, repo, cfg, authzReq, sso, client)
+	if err != nil {
+		return nil, err
+	}
+	return &uModels.LoginResult{
+		AuthorizationRedirect: redirect,
+		SSOSessionId:          ssoSessionId,
+	}, nil
+}
+
+// issueAuthorizationCode lưu code vào redis và trả về redirect_uri kèm code và state
+func issueAuthorizationCode(ctx context.Context, repo rInterfaces.Repo, cfg utils.Config, authzReq *models.AuthorizationRequest, sso *models.SSOSession, client uModels.ClientInfo) (string, error) {
+	code, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", fmt.Errorf("cannot generate authorization code: %w", err)
+	}
+	err = repo.Redis().SaveAuthorizationCode(ctx, code, &models.AuthorizationCode{
+		ClientId:            authzReq.ClientId,
+		RedirectUri:         authzReq.RedirectUri,
+		RedirectUriProvided: authzReq.RedirectUriProvided,
+		Scope:               authzReq.Scope,
+		Nonce:               authzReq.Nonce,
+		CodeChallenge:       authzReq.CodeChallenge,
+		CodeChallengeMethod: authzReq.CodeChallengeMethod,
+		UserId:              sso.UserId,
+		AuthTime:            sso.AuthTime,
+		SSOSid:              sso.Sid,
+		UserAgent:           client.UserAgent,
+		IPAddress:           client.IPAddress,
+		CreatedAt:           time.Now(),
+	}, time.Duration(cfg.AuthorizationCodeTimeLife)*time.Second)
+	if err != nil {
+		return "", err
+	}
+	return authorizationRedirect(cfg, authzReq.RedirectUri, url.Values{"code": {code}}, authzReq.State), nil
+}
+
+// authorizationRedirect thêm params, state và iss (RFC 9207) vào query của redirect_uri
+func authorizationRedirect(cfg utils.Config, redirectUri string, params url.Values, state string) string {
+	u, err := url.Parse(redirectUri)
+	if err != nil {
+		return redirectUri
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	query.Set("iss", cfg.JWTIssuer)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func verifyCodeChallenge(verifier string, challenge string) bool {
+	if !pkceValue.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// activeUser lấy user và từ chối user bị block/ban
+func activeUser(ctx context.Context, repo rInterfaces.Repo, userId uuid.UUID) (*models.User, error) {
+	user, err := repo.Auth().GetUserByUserId(ctx, userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, uModels.ErrUserNotActive
+		}
+		return nil, err
+	}
+	if user.Status == uModels.StatusBlocked || user.Status == uModels.StatusBanned {
+		return nil, uModels.ErrUserNotActive
+	}
+	return user, nil
+}
+
+func newTokenResponse(tokens *tokenPair, scope string) *uModels.TokenResponse {
+	return &uModels.TokenResponse{
+		AccessToken:  tokens.accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(tokens.accessExpiresAt).Seconds()),
+		RefreshToken: tokens.refreshToken,
+		Scope:        scope,
+	}
+}
+
+// toInvalidGrant đổi lỗi nghiệp vụ của refresh token / user thành invalid_grant,
+// lỗi hạ tầng giữ nguyên để handler trả 500
+func toInvalidGrant(err error) error {
+	switch {
+	case errors.Is(err, uModels.ErrInvalidRefreshToken),
+		errors.Is(err, uModels.ErrRefreshTokenReused),
+		errors.Is(err, uModels.ErrSessionRevoked),
+		errors.Is(err, uModels.ErrSessionBlocked),
+		errors.Is(err, uModels.ErrUserNotActive):
+		return uModels.NewOAuthError("invalid_grant", err.Error())
+	}
+	return err
+}
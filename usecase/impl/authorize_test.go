@@ -0,0 +1,160 @@
+package impl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+)
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	verifier := strings.Repeat("a", 43)
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{name: "match", verifier: verifier, challenge: codeChallenge(verifier), want: true},
+		{name: "max length", verifier: strings.Repeat("-._~", 32), challenge: codeChallenge(strings.Repeat("-._~", 32)), want: true},
+		{name: "different verifier", verifier: strings.Repeat("b", 43), challenge: codeChallenge(verifier)},
+		{name: "plain challenge", verifier: verifier, challenge: verifier},
+		{name: "too short", verifier: strings.Repeat("a", 42), challenge: codeChallenge(strings.Repeat("a", 42))},
+		{name: "too long", verifier: strings.Repeat("a", 129), challenge: codeChallenge(strings.Repeat("a", 129))},
+		{name: "invalid characters", verifier: strings.Repeat("a", 42) + "+", challenge: codeChallenge(strings.Repeat("a", 42) + "+")},
+		{name: "empty", verifier: "", challenge: codeChallenge("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyCodeChallenge(tt.verifier, tt.challenge); got != tt.want {
+				t.Fatalf("verifyCodeChallenge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveRedirectUri(t *testing.T) {
+	single := &models.OAuthClient{RedirectUris: "https://app.example/callback"}
+	multiple := &models.OAuthClient{RedirectUris: "https://app.example/callback https://app.example/other"}
+	tests := []struct {
+		name        string
+		client      *models.OAuthClient
+		redirectUri string
+		want        string
+		wantErr     bool
+	}{
+		{name: "single registered uri is the default", client: single, want: "https://app.example/callback"},
+		{name: "exact match", client: multiple, redirectUri: "https://app.example/other", want: "https://app.example/other"},
+		{name: "missing with several registered", client: multiple, wantErr: true},
+		{name: "prefix is not a match", client: single, redirectUri: "https://app.example/callback/evil", wantErr: true},
+		{name: "query is not a match", client: single, redirectUri: "https://app.example/callback?x=1", wantErr: true},
+		{name: "case differs", client: single, redirectUri: "https://APP.example/callback", wantErr: true},
+		{name: "no registered uri", client: &models.OAuthClient{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveRedirectUri(tt.client, tt.redirectUri)
+			if tt.wantErr {
+				if !errors.Is(err, uModels.ErrInvalidRedirectUri) {
+					t.Fatalf("resolveRedirectUri() error = %v, want ErrInvalidRedirectUri", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("resolveRedirectUri() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeAuthorizationCodeRedirectUri(t *testing.T) {
+	const registered = "https://app.example/callback"
+	tests := []struct {
+		name        string
+		provided    bool
+		redirectUri string
+		wantErr     bool
+	}{
+		{name: "provided and repeated", provided: true, redirectUri: registered},
+		{name: "provided but omitted", provided: true, wantErr: true},
+		{name: "provided but different", provided: true, redirectUri: "https://app.example/other", wantErr: true},
+		{name: "defaulted and omitted"},
+		{name: "defaulted and repeated", redirectUri: registered},
+		{name: "defaulted but different", redirectUri: "https://app.example/other", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redis := &fakeRedis{codes: map[string]*models.AuthorizationCode{
+				"code": {
+					ClientId:            "app",
+					RedirectUri:         registered,
+					RedirectUriProvided: tt.provided,
+					CodeChallenge:       codeChallenge(strings.Repeat("a", 43)),
+				},
+			}}
+			s := &OAuthServerImpl{repo: fakeRepo{redis: redis}, cfg: testConfig(t)}
+			// code_verifier sai để dừng ngay sau bước so khớp redirect_uri
+			_, err := s.exchangeAuthorizationCode(context.Background(), &uModels.Client{ClientId: "app"}, uModels.TokenRequest{
+				Code:         "code",
+				CodeVerifier: strings.Repeat("b", 43),
+				RedirectUri:  tt.redirectUri,
+			})
+			var oauthErr *uModels.OAuthError
+			if !errors.As(err, &oauthErr) {
+				t.Fatalf("exchangeAuthorizationCode() error = %v, want OAuthError", err)
+			}
+			rejected := strings.Contains(oauthErr.Description, "redirect_uri")
+			if rejected != tt.wantErr {
+				t.Fatalf("exchangeAuthorizationCode() error = %v, want redirect_uri rejected %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExchangeReplayedAuthorizationCode(t *testing.T) {
+	sessionId := uuid.New()
+	tests := []struct {
+		name        string
+		used        map[string]string
+		wantRevoked []uuid.UUID
+		wantUsed    string
+	}{
+		{name: "never issued", used: map[string]string{}},
+		{name: "replayed after tokens were issued", used: map[string]string{"code": sessionId.String()}, wantRevoked: []uuid.UUID{sessionId}, wantUsed: sessionId.String()},
+		// lần đổi đầu chưa ghi session: đánh dấu để lần đổi đó tự thu hồi
+		{name: "replayed while exchanging", used: map[string]string{"code": ""}, wantUsed: "replayed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &fakeAuth{}
+			redis := &fakeRedis{used: tt.used}
+			s := &OAuthServerImpl{repo: fakeRepo{auth: auth, redis: redis}, cfg: testConfig(t)}
+			_, err := s.exchangeAuthorizationCode(context.Background(), &uModels.Client{ClientId: "app"}, uModels.TokenRequest{
+				Code:         "code",
+				CodeVerifier: strings.Repeat("a", 43),
+			})
+			var oauthErr *uModels.OAuthError
+			if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_grant" {
+				t.Fatalf("exchangeAuthorizationCode() error = %v, want invalid_grant", err)
+			}
+			if !slices.Equal(auth.revoked, tt.wantRevoked) {
+				t.Fatalf("revoked = %v, want %v", auth.revoked, tt.wantRevoked)
+			}
+			if got := tt.used["code"]; got != tt.wantUsed {
+				t.Fatalf("used marker = %q, want %q", got, tt.wantUsed)
+			}
+		})
+	}
+}
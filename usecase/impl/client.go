@@ -0,0 +1,392 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	AuthMethodNone              = "none"
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+)
+
+// supportedGrantTypes là các grant type client được phép đăng ký
+var supportedGrantTypes = []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken, GrantTypeClientCredentials, GrantTypeDeviceCode, GrantTypeTokenExchange}
+
+// defaultGrantTypes áp dụng khi request tạo client có redirect uri nhưng không chỉ định grant_types.
+// Client không có redirect uri lẫn grant type chỉ gọi được introspect/revoke (resource server).
+var defaultGrantTypes = []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken}
+
+// CreateClient tạo client mới, secret chỉ lưu bcrypt hash nên chỉ trả về một lần ở đây.
+// Client public không có secret.
+func (s *OAuthServerImpl) CreateClient(ctx context.Context, req uModels.CreateClientRequest) (*uModels.ClientCredentials, error) {
+	if len(req.GrantTypes) == 0 && len(req.RedirectUris) > 0 {
+		req.GrantTypes = defaultGrantTypes
+	}
+	if err := s.validateClient(req); err != nil {
+		return nil, err
+	}
+	jwks, err := normalizeClientJwks(req.Jwks)
+	if err != nil {
+		return nil, err
+	}
+	clientId, err := utils.GenerateRandomString(16)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate client id: %w", err)
+	}
+	var secret, hash string
+	if !req.IsPublic {
+		secret, hash, err = newClientSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+	client := &models.OAuthClient{
+		Id:                                 uuid.New(),
+		ClientId:                           clientId,
+		ClientSecretHash:                   hash,
+		Name:                               strings.TrimSpace(req.Name),
+		RedirectUris:                       strings.Join(req.RedirectUris, " "),
+		Scopes:                             strings.Join(req.Scopes, " "),
+		GrantTypes:                         strings.Join(req.GrantTypes, " "),
+		IsPublic:                           req.IsPublic,
+		FirstParty:                         req.FirstParty,
+		Jwks:                               jwks,
+		RequirePushedAuthorizationRequests: req.RequirePushedAuthorizationRequests,
+		RequireSignedRequestObject:         req.RequireSignedRequestObject,
+		PostLogoutRedirectUris:             strings.Join(req.PostLogoutRedirectUris, " "),
+		BackchannelLogoutUri:               req.BackchannelLogoutUri,
+		FrontchannelLogoutUri:              req.FrontchannelLogoutUri,
+		TokenExchangeAudiences:             strings.Join(req.TokenExchangeAudiences, " "),
+		AccessTokenTimeLife:                req.AccessTokenTimeLife,
+		RefreshTokenTimeLife:               req.RefreshTokenTimeLife,
+	}
+	if err := s.repo.Client().CreateClient(ctx, client); err != nil {
+		return nil, fmt.Errorf("create client error: %w", err)
+	}
+	return &uModels.ClientCredentials{
+		Client:       toClient(client),
+		ClientSecret: secret,
+	}, nil
+}
+
+// RegisterClient là dynamic client registration theo RFC 7591
+func (s *OAuthServerImpl) RegisterClient(ctx context.Context, req uModels.ClientRegistrationRequest) (*uModels.ClientRegistrationResponse, error) {
+	authMethod := req.TokenEndpointAuthMethod
+	if authMethod == "" {
+		authMethod = AuthMethodClientSecretBasic
+	}
+	if !slices.Contains([]string{AuthMethodNone, AuthMethodClientSecretBasic, AuthMethodClientSecretPost}, authMethod) {
+		return nil, fmt.Errorf("%w: token_endpoint_auth_method %q is not supported", uModels.ErrInvalidClientMetadata, authMethod)
+	}
+	grantTypes := req.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{GrantTypeAuthorizationCode}
+	}
+	// response_types phải nhất quán với grant_types (RFC 7591 mục 2.1)
+	responseTypes := req.ResponseTypes
+	if len(responseTypes) == 0 && slices.Contains(grantTypes, GrantTypeAuthorizationCode) {
+		responseTypes = []string{"code"}
+	}
+	for _, responseType := range responseTypes {
+		if responseType != "code" {
+			return nil, fmt.Errorf("%w: response_type %q is not supported", uModels.ErrInvalidClientMetadata, responseType)
+		}
+	}
+	if slices.Contains(responseTypes, "code") != slices.Contains(grantTypes, GrantTypeAuthorizationCode) {
+		return nil, fmt.Errorf("%w: response_types and grant_types are inconsistent", uModels.ErrInvalidClientMetadata)
+	}
+	// không xin scope thì cấp các scope cơ bản, offline_access phải xin rõ ràng
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		for _, scope := range strings.Fields(s.cfg.OAuthScopes) {
+			if scope != ScopeOfflineAccess {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	// server gọi tới backchannel_logout_uri nên client tự đăng ký không được trỏ vào mạng nội bộ
+	if req.BackchannelLogoutUri != "" {
+		if err := validatePublicUri(req.BackchannelLogoutUri); err != nil {
+			return nil, fmt.Errorf("%w: backchannel_logout_uri %v", uModels.ErrInvalidClientMetadata, err)
+		}
+	}
+	name := strings.TrimSpace(req.ClientName)
+	if name == "" {
+		name = "Unnamed client"
+	}
+
+	created, err := s.CreateClient(ctx, uModels.CreateClientRequest{
+		Name:                               name,
+		RedirectUris:                       req.RedirectUris,
+		Scopes:                             scopes,
+		GrantTypes:                         grantTypes,
+		IsPublic:                           authMethod == AuthMethodNone,
+		Jwks:                               req.Jwks,
+		RequirePushedAuthorizationRequests: req.RequirePushedAuthorizationRequests,
+		RequireSignedRequestObject:         req.RequireSignedRequestObject,
+		PostLogoutRedirectUris:             req.PostLogoutRedirectUris,
+		BackchannelLogoutUri:               req.BackchannelLogoutUri,
+		FrontchannelLogoutUri:              req.FrontchannelLogoutUri,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := &uModels.ClientRegistrationResponse{
+		ClientId:                           created.ClientId,
+		ClientSecret:                       created.ClientSecret,
+		ClientIdIssuedAt:                   created.CreatedAt.Unix(),
+		ClientName:                         created.Name,
+		RedirectUris:                       created.RedirectUris,
+		GrantTypes:                         created.GrantTypes,
+		ResponseTypes:                      responseTypes,
+		TokenEndpointAuthMethod:            authMethod,
+		Scope:                              strings.Join(created.Scopes, " "),
+		Jwks:                               created.Jwks,
+		RequirePushedAuthorizationRequests: created.RequirePushedAuthorizationRequests,
+		RequireSignedRequestObject:         created.RequireSignedRequestObject,
+		PostLogoutRedirectUris:             created.PostLogoutRedirectUris,
+		BackchannelLogoutUri:               created.BackchannelLogoutUri,
+		FrontchannelLogoutUri:              created.FrontchannelLogoutUri,
+	}
+	// secret không hết hạn: client_secret_expires_at = 0
+	if created.ClientSecret != "" {
+		var never int64
+		result.ClientSecretExpiresAt = &never
+	}
+	return result, nil
+}
+
+func (s *OAuthServerImpl) ListClients(ctx context.Context) ([]uModels.Client, error) {
+	clients, err := s.repo.Client().ListClients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]uModels.Client, 0, len(clients))
+	for i := range clients {
+		result = append(result, toClient(&clients[i]))
+	}
+	return result, nil
+}
+
+func (s *OAuthServerImpl) GetClient(ctx context.Context, id uuid.UUID) (*uModels.Client, error) {
+	client, err := s.getClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := toClient(client)
+	return &result, nil
+}
+
+// RotateClientSecret cấp secret mới, secret cũ hết hiệu lực ngay
+func (s *OAuthServerImpl) RotateClientSecret(ctx context.Context, id uuid.UUID) (*uModels.ClientCredentials, error) {
+	client, err := s.getClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if client.IsPublic {
+		return nil, uModels.ErrPublicClient
+	}
+	secret, hash, err := newClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Client().UpdateClientSecretHash(ctx, client.Id, hash); err != nil {
+		return nil, err
+	}
+	return &uModels.ClientCredentials{
+		Client:       toClient(client),
+		ClientSecret: secret,
+	}, nil
+}
+
+// DisableClient chặn client xác thực và thu hồi mọi session đã cấp cho client
+func (s *OAuthServerImpl) DisableClient(ctx context.Context, id uuid.UUID) error {
+	client, err := s.getClient(ctx, id)
+	if err != nil {
+		return err
+	}
+	if client.DisabledAt == nil {
+		now := time.Now().UTC()
+		if err := s.repo.Client().SetClientDisabledAt(ctx, client.Id, &now); err != nil {
+			return err
+		}
+	}
+	sessionIds, err := s.repo.Auth().RevokeSessionsByClientId(ctx, client.ClientId)
+	if err != nil {
+		return err
+	}
+	return revokeSessions(ctx, s.repo, s.cfg, sessionIds...)
+}
+
+func (s *OAuthServerImpl) EnableClient(ctx context.Context, id uuid.UUID) error {
+	client, err := s.getClient(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.repo.Client().SetClientDisabledAt(ctx, client.Id, nil)
+}
+
+func (s *OAuthServerImpl) getClient(ctx context.Context, id uuid.UUID) (*models.OAuthClient, error) {
+	client, err := s.repo.Client().GetClientById(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, uModels.ErrClientNotFound
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// validateClient kiểm tra metadata của client. Thời hạn token của client chỉ được ngắn hơn
+// mặc định của server để các mốc thu hồi trong redis luôn sống lâu hơn token.
+func (s *OAuthServerImpl) validateClient(req uModels.CreateClientRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("%w: name is required", uModels.ErrInvalidClientMetadata)
+	}
+	for i, grantType := range req.GrantTypes {
+		if !slices.Contains(supportedGrantTypes, grantType) {
+			return fmt.Errorf("%w: grant_type %q is not supported", uModels.ErrInvalidClientMetadata, grantType)
+		}
+		if slices.Contains(req.GrantTypes[:i], grantType) {
+			return fmt.Errorf("%w: duplicate grant_type %q", uModels.ErrInvalidClientMetadata, grantType)
+		}
+	}
+	if slices.Contains(req.GrantTypes, GrantTypeRefreshToken) &&
+		!slices.Contains(req.GrantTypes, GrantTypeAuthorizationCode) && !slices.Contains(req.GrantTypes, GrantTypeDeviceCode) {
+		return fmt.Errorf("%w: refresh_token requires authorization_code or the device code grant", uModels.ErrInvalidClientMetadata)
+	}
+	if slices.Contains(req.GrantTypes, GrantTypeClientCredentials) && req.IsPublic {
+		return fmt.Errorf("%w: client_credentials requires a confidential client", uModels.ErrInvalidClientMetadata)
+	}
+	if err := s.validateTokenExchangePolicy(req); err != nil {
+		return err
+	}
+	if slices.Contains(req.GrantTypes, GrantTypeAuthorizationCode) && len(req.RedirectUris) == 0 {
+		return fmt.Errorf("%w: authorization_code requires at least one redirect uri", uModels.ErrInvalidClientRedirect)
+	}
+	if err := validateRedirectUris(req.RedirectUris); err != nil {
+		return err
+	}
+	// URI logout theo cùng quy tắc với redirect uri
+	logoutUris := slices.Clone(req.PostLogoutRedirectUris)
+	for _, uri := range []string{req.BackchannelLogoutUri, req.FrontchannelLogoutUri} {
+		if uri != "" {
+			logoutUris = append(logoutUris, uri)
+		}
+	}
+	if err := validateRedirectUris(logoutUris); err != nil {
+		return err
+	}
+	supported := strings.Fields(s.cfg.OAuthScopes)
+	for _, scope := range req.Scopes {
+		if !slices.Contains(supported, scope) {
+			return fmt.Errorf("%w: scope %q is not supported", uModels.ErrInvalidClientMetadata, scope)
+		}
+	}
+	if req.RequireSignedRequestObject && len(req.Jwks) == 0 {
+		return fmt.Errorf("%w: require_signed_request_object requires jwks", uModels.ErrInvalidClientMetadata)
+	}
+	if req.AccessTokenTimeLife > s.cfg.AccessTokenTimeLife {
+		return fmt.Errorf("%w: access_token_time_life cannot exceed %d minutes", uModels.ErrInvalidClientMetadata, s.cfg.AccessTokenTimeLife)
+	}
+	if req.RefreshTokenTimeLife > s.cfg.RefreshTokenTimeLife {
+		return fmt.Errorf("%w: refresh_token_time_life cannot exceed %d hours", uModels.ErrInvalidClientMetadata, s.cfg.RefreshTokenTimeLife)
+	}
+	return nil
+}
+
+// validateRedirectUris chỉ nhận URI tuyệt đối, không có fragment (RFC 6749 mục 3.1.2).
+// http chỉ được dùng cho loopback (RFC 8252 mục 7.3).
+func validateRedirectUris(uris []string) error {
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+			return fmt.Errorf("%w: %q", uModels.ErrInvalidClientRedirect, uri)
+		}
+		if u.Scheme == "http" && !isLoopback(u.Hostname()) {
+			return fmt.Errorf("%w: %q must use https", uModels.ErrInvalidClientRedirect, uri)
+		}
+	}
+	return nil
+}
+
+// validatePublicUri: URI https có host không phải localhost hay IP nội bộ. Tên miền trỏ về IP
+// nội bộ bị chặn lúc kết nối (xem utils.PublicOnlyDialControl).
+func validatePublicUri(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return fmt.Errorf("must be an https url")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("must not point to a loopback host")
+	}
+	if ip := net.ParseIP(host); ip != nil && !utils.IsPublicIP(ip) {
+		return fmt.Errorf("must not point to a loopback, private or link-local address")
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// newClientSecret trả về secret và bcrypt hash để lưu
+func newClientSecret() (string, string, error) {
+	secret, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot generate client secret: %w", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot hash client secret: %w", err)
+	}
+	return secret, string(hash), nil
+}
+
+func toClient(client *models.OAuthClient) uModels.Client {
+	result := uModels.Client{
+		Id:                                 client.Id,
+		ClientId:                           client.ClientId,
+		Name:                               client.Name,
+		RedirectUris:                       strings.Fields(client.RedirectUris),
+		Scopes:                             strings.Fields(client.Scopes),
+		GrantTypes:                         strings.Fields(client.GrantTypes),
+		IsPublic:                           client.IsPublic,
+		FirstParty:                         client.FirstParty,
+		RequirePushedAuthorizationRequests: client.RequirePushedAuthorizationRequests,
+		RequireSignedRequestObject:         client.RequireSignedRequestObject,
+		PostLogoutRedirectUris:             strings.Fields(client.PostLogoutRedirectUris),
+		BackchannelLogoutUri:               client.BackchannelLogoutUri,
+		FrontchannelLogoutUri:              client.FrontchannelLogoutUri,
+		TokenExchangeAudiences:             strings.Fields(client.TokenExchangeAudiences),
+		AccessTokenTimeLife:                client.AccessTokenTimeLife,
+		RefreshTokenTimeLife:               client.RefreshTokenTimeLife,
+		DisabledAt:                         client.DisabledAt,
+		CreatedAt:                          client.CreatedAt,
+	}
+	if client.Jwks != "" {
+		result.Jwks = json.RawMessage(client.Jwks)
+	}
+	return result
+}
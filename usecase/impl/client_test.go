@@ -0,0 +1,70 @@
+package impl
+
+import (
+	"errors"
+	"testing"
+
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+)
+
+func TestValidateRedirectUris(t *testing.T) {
+	tests := []struct {
+		name    string
+		uris    []string
+		wantErr bool
+	}{
+		{name: "https", uris: []string{"https://app.example/callback"}},
+		{name: "http localhost", uris: []string{"http://localhost:3000/callback"}},
+		{name: "http loopback ipv4", uris: []string{"http://127.0.0.1:8080/callback"}},
+		{name: "http loopback ipv6", uris: []string{"http://[::1]/callback"}},
+		{name: "private-use scheme", uris: []string{"com.example.app://callback"}},
+		{name: "empty list", uris: nil},
+		{name: "http non-loopback", uris: []string{"http://app.example/callback"}, wantErr: true},
+		{name: "relative", uris: []string{"/callback"}, wantErr: true},
+		{name: "no host", uris: []string{"https:///callback"}, wantErr: true},
+		{name: "fragment", uris: []string{"https://app.example/callback#x"}, wantErr: true},
+		{name: "unparseable", uris: []string{"https://app.example/%zz"}, wantErr: true},
+		{name: "one bad uri fails the list", uris: []string{"https://app.example/callback", "http://evil.example/"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRedirectUris(tt.uris)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("validateRedirectUris() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, uModels.ErrInvalidClientRedirect) {
+				t.Fatalf("validateRedirectUris() error = %v, want ErrInvalidClientRedirect", err)
+			}
+		})
+	}
+}
+
+func TestValidatePublicUri(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		wantErr bool
+	}{
+		{name: "public host", uri: "https://app.example/logout"},
+		{name: "public ip", uri: "https://8.8.8.8/logout"},
+		{name: "http", uri: "http://app.example/logout", wantErr: true},
+		{name: "localhost", uri: "https://localhost/logout", wantErr: true},
+		{name: "localhost subdomain", uri: "https://api.localhost./logout", wantErr: true},
+		{name: "loopback", uri: "https://127.0.0.1/logout", wantErr: true},
+		{name: "loopback ipv6", uri: "https://[::1]/logout", wantErr: true},
+		{name: "cloud metadata", uri: "https://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "private range", uri: "https://10.0.0.5/logout", wantErr: true},
+		{name: "private range 192.168", uri: "https://192.168.1.1:8443/logout", wantErr: true},
+		{name: "unique local ipv6", uri: "https://[fd00::1]/logout", wantErr: true},
+		{name: "ipv4-mapped ipv6 loopback", uri: "https://[::ffff:127.0.0.1]/logout", wantErr: true},
+		{name: "unspecified", uri: "https://0.0.0.0/logout", wantErr: true},
+		{name: "no host", uri: "https:///logout", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePublicUri(tt.uri); (err != nil) != tt.wantErr {
+				t.Fatalf("validatePublicUri(%q) error = %v, wantErr %v", tt.uri, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package impl
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"gorm.io/gorm"
+)
+
+const consentPath = "/v1/oauth/consent"
+
+// scopeDescriptions là mô tả scope hiển thị ở màn hình đồng ý, scope khác hiện nguyên tên
+var scopeDescriptions = map[string]string{
+	ScopeOpenId:        "Sign you in with your account",
+	ScopeProfile:       "See your name and profile picture",
+	ScopeEmail:         "See your email address",
+	ScopeOfflineAccess: "Keep access while you are not using the app",
+}
+
+// GetConsentRequest trả về thông tin cho màn hình đồng ý. Trình duyệt chưa đăng nhập thì
+// LoginURL trỏ tới trang chọn provider với cùng authorization request.
+func (s *OAuthServerImpl) GetConsentRequest(ctx context.Context, ssoSessionId string, requestId string) (*uModels.ConsentRequest, error) {
+	authzReq, err := s.repo.Redis().GetAuthorizationRequest(ctx, requestId)
+	if err != nil {
+		return nil, err
+	}
+	if authzReq == nil || authzReq.DeviceUserCode != "" {
+		return nil, uModels.ErrInvalidAuthzRequest
+	}
+	client, err := s.repo.Client().GetClientByClientId(ctx, authzReq.ClientId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, uModels.ErrInvalidAuthzRequest
+		}
+		return nil, err
+	}
+	if client.DisabledAt != nil {
+		return nil, uModels.ErrInvalidAuthzRequest
+	}
+	result := &uModels.ConsentRequest{
+		RequestId:  requestId,
+		ClientName: client.Name,
+	}
+	for _, scope := range strings.Fields(authzReq.Scope) {
+		description, ok := scopeDescriptions[scope]
+		if !ok {
+			description = scope
+		}
+		result.Scopes = append(result.Scopes, uModels.ConsentScope{Name: scope, Description: description})
+	}
+	sso, err := s.ssoSession(ctx, ssoSessionId)
+	if err != nil {
+		return nil, err
+	}
+	if sso == nil {
+		result.LoginURL = "/v1/oauth/login?" + url.Values{"request_id": {requestId}}.Encode()
+		return result, nil
+	}
+	// gắn request với phiên đang xem trang, tải lại trang của cùng phiên giữ nguyên token
+	if authzReq.ConsentToken == "" || authzReq.ConsentSid != sso.Sid {
+		token, err := utils.GenerateRandomString(32)
+		if err != nil {
+			return nil, err
+		}
+		authzReq.ConsentSid = sso.Sid
+		authzReq.ConsentToken = token
+		updated, err := s.repo.Redis().UpdateAuthorizationRequest(ctx, requestId, authzReq)
+		if err != nil {
+			return nil, err
+		}
+		if !updated {
+			return nil, uModels.ErrInvalidAuthzRequest
+		}
+	}
+	result.CsrfToken = authzReq.ConsentToken
+	return result, nil
+}
+
+// Consent ghi quyết định của user đang đăng nhập cho authorization request và trả về
+// redirect_uri của client kèm code hoặc access_denied. Request chỉ được quyết định bởi
+// phiên đã xem màn hình đồng ý và form phải gửi lại đúng csrfToken của trang đó.
+func (s *OAuthServerImpl) Consent(ctx context.Context, ssoSessionId string, requestId string, csrfToken string, approve bool, client uModels.ClientInfo) (string, error) {
+	sso, err := s.ssoSession(ctx, ssoSessionId)
+	if err != nil {
+		return "", err
+	}
+	if sso == nil {
+		return "", uModels.ErrLoginRequired
+	}
+	authzReq, err := s.repo.Redis().ConsumeAuthorizationRequest(ctx, requestId)
+	if err != nil {
+		return "", err
+	}
+	if authzReq == nil || authzReq.DeviceUserCode != "" {
+		return "", uModels.ErrInvalidAuthzRequest
+	}
+	if authzReq.ConsentToken == "" || authzReq.ConsentSid != sso.Sid ||
+		subtle.ConstantTimeCompare([]byte(authzReq.ConsentToken), []byte(csrfToken)) != 1 {
+		return "", uModels.ErrInvalidAuthzRequest
+	}
+	if !approve {
+		return authorizationRedirect(s.cfg, authzReq.RedirectUri, url.Values{
+			"error":             {"access_denied"},
+			"error_description": {"the user denied the request"},
+		}, authzReq.State), nil
+	}
+	if err := grantConsent(ctx, s.repo, sso.UserId, authzReq.ClientId, authzReq.Scope); err != nil {
+		return "", err
+	}
+	return issueAuthorizationCode(ctx, s.repo, s.cfg, authzReq, sso, client)
+}
+
+// ListAuthorizedApps trả về các app user đã đồng ý cấp quyền
+func (u AuthImpl) ListAuthorizedApps(ctx context.Context, userId uuid.UUID) ([]uModels.AuthorizedApp, error) {
+	consents, err := u.repo.Client().ListConsentsByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]uModels.AuthorizedApp, 0, len(consents))
+	for _, consent := range consents {
+		client, err := u.repo.Client().GetClientByClientId(ctx, consent.ClientId)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, uModels.AuthorizedApp{
+			ClientId:  client.ClientId,
+			Name:      client.Name,
+			Scopes:    strings.Fields(consent.Scope),
+			GrantedAt: consent.CreatedAt,
+			UpdatedAt: consent.UpdatedAt,
+		})
+	}
+	return result, nil
+}
+
+// RevokeAuthorizedApp xoá quyền đã cấp cho app và thu hồi mọi session (refresh token,
+// access token còn hạn) user đã cấp cho app đó
+func (u AuthImpl) RevokeAuthorizedApp(ctx context.Context, userId uuid.UUID, clientId string) error {
+	if err := u.repo.Client().DeleteConsent(ctx, userId, clientId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uModels.ErrAuthorizedAppNotFound
+		}
+		return err
+	}
+	sessionIds, err := u.repo.Auth().RevokeSessionsByUserIdAndClientId(ctx, userId, clientId)
+	if err != nil {
+		return err
+	}
+	return revokeSessions(ctx, u.repo, u.cfg, sessionIds...)
+}
+
+// needsConsent: prompt=consent luôn hỏi lại, client bên thứ nhất không cần hỏi,
+// client khác cần khi user chưa đồng ý đủ các scope đang xin
+func needsConsent(ctx context.Context, repo rInterfaces.Repo, client *models.OAuthClient, userId uuid.UUID, scope string, prompt string) (bool, error) {
+	if prompt == "consent" {
+		return true, nil
+	}
+	if client.FirstParty {
+		return false, nil
+	}
+	consent, err := repo.Client().GetConsent(ctx, userId, client.ClientId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+	granted := strings.Fields(consent.Scope)
+	for _, requested := range strings.Fields(scope) {
+		if !slices.Contains(granted, requested) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// grantConsent gộp scope vừa đồng ý vào các scope đã đồng ý trước đó
+func grantConsent(ctx context.Context, repo rInterfaces.Repo, userId uuid.UUID, clientId string, scope string) error {
+	scopes := strings.Fields(scope)
+	consent, err := repo.Client().GetConsent(ctx, userId, clientId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if consent != nil {
+		for _, granted := range strings.Fields(consent.Scope) {
+			if !slices.Contains(scopes, granted) {
+				scopes = append(scopes, granted)
+			}
+		}
+	}
+	return repo.Client().SaveConsent(ctx, &models.UserConsent{
+		Id:       uuid.New(),
+		UserId:   userId,
+		ClientId: clientId,
+		Scope:    strings.Join(scopes, " "),
+	})
+}
+
+func consentURL(requestId string) string {
+	return consentPath + "?" + url.Values{"request_id": {requestId}}.Encode()
+}
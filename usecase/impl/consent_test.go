@@ -0,0 +1,121 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+)
+
+// fakeConsentRedis giữ authorization request và SSO session theo cookie
+type fakeConsentRedis struct {
+	fakeRedis
+	sso      map[string]*models.SSOSession
+	requests map[string]*models.AuthorizationRequest
+}
+
+func (r *fakeConsentRedis) GetSSOSession(_ context.Context, id string) (*models.SSOSession, error) {
+	return r.sso[id], nil
+}
+
+func (r *fakeConsentRedis) GetAuthorizationRequest(_ context.Context, id string) (*models.AuthorizationRequest, error) {
+	authzReq, ok := r.requests[id]
+	if !ok {
+		return nil, nil
+	}
+	data := *authzReq
+	return &data, nil
+}
+
+func (r *fakeConsentRedis) UpdateAuthorizationRequest(_ context.Context, id string, data *models.AuthorizationRequest) (bool, error) {
+	if _, ok := r.requests[id]; !ok {
+		return false, nil
+	}
+	stored := *data
+	r.requests[id] = &stored
+	return true, nil
+}
+
+func (r *fakeConsentRedis) ConsumeAuthorizationRequest(_ context.Context, id string) (*models.AuthorizationRequest, error) {
+	authzReq := r.requests[id]
+	delete(r.requests, id)
+	return authzReq, nil
+}
+
+func (r *fakeConsentRedis) SaveAuthorizationCode(_ context.Context, code string, data *models.AuthorizationCode, _ time.Duration) error {
+	r.codes[code] = data
+	return nil
+}
+
+func TestConsentBoundToViewingSession(t *testing.T) {
+	victim := &models.User{Id: uuid.New(), Status: uModels.StatusActive}
+	attacker := &models.User{Id: uuid.New(), Status: uModels.StatusActive}
+	tests := []struct {
+		name string
+		// viewer là cookie đã mở màn hình đồng ý, submitter là cookie gửi form
+		viewer    string
+		submitter string
+		token     func(viewed string) string
+		wantErr   bool
+	}{
+		{name: "same session and token", viewer: "victim-cookie", submitter: "victim-cookie", token: func(viewed string) string { return viewed }},
+		{name: "other session replays the form", viewer: "attacker-cookie", submitter: "victim-cookie", token: func(viewed string) string { return viewed }, wantErr: true},
+		{name: "missing token", viewer: "victim-cookie", submitter: "victim-cookie", token: func(string) string { return "" }, wantErr: true},
+		{name: "wrong token", viewer: "victim-cookie", submitter: "victim-cookie", token: func(string) string { return "guess" }, wantErr: true},
+		{name: "page never viewed", submitter: "victim-cookie", token: func(string) string { return "" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redis := &fakeConsentRedis{
+				fakeRedis: fakeRedis{codes: map[string]*models.AuthorizationCode{}},
+				sso: map[string]*models.SSOSession{
+					"victim-cookie":   {Sid: "victim-sid", UserId: victim.Id},
+					"attacker-cookie": {Sid: "attacker-sid", UserId: attacker.Id},
+				},
+				requests: map[string]*models.AuthorizationRequest{
+					"request": {ClientId: "app", RedirectUri: "https://app.example/callback", Scope: "openid"},
+				},
+			}
+			client := &fakeClient{clients: map[string]*models.OAuthClient{"app": {ClientId: "app", Name: "App"}}}
+			s := &OAuthServerImpl{
+				repo: fakeRepo{
+					auth:   &fakeAuth{users: map[uuid.UUID]*models.User{victim.Id: victim, attacker.Id: attacker}},
+					client: client,
+					redis:  redis,
+				},
+				cfg: testConfig(t),
+			}
+			var viewed string
+			if tt.viewer != "" {
+				info, err := s.GetConsentRequest(context.Background(), tt.viewer, "request")
+				if err != nil {
+					t.Fatal(err)
+				}
+				viewed = info.CsrfToken
+				// tải lại trang của cùng phiên giữ nguyên token
+				again, err := s.GetConsentRequest(context.Background(), tt.viewer, "request")
+				if err != nil || again.CsrfToken != viewed {
+					t.Fatalf("reload token = %q, %v, want %q", again.CsrfToken, err, viewed)
+				}
+			}
+			redirect, err := s.Consent(context.Background(), tt.submitter, "request", tt.token(viewed), true, uModels.ClientInfo{})
+			if tt.wantErr {
+				if !errors.Is(err, uModels.ErrInvalidAuthzRequest) {
+					t.Fatalf("Consent() error = %v, want ErrInvalidAuthzRequest", err)
+				}
+				if len(client.consents) != 0 || len(redis.codes) != 0 {
+					t.Fatal("rejected consent must not grant scopes or issue a code")
+				}
+				return
+			}
+			if err != nil || !strings.Contains(redirect, "code=") {
+				t.Fatalf("Consent() = %q, %v, want redirect with code", redirect, err)
+			}
+		})
+	}
+}
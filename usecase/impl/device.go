@@ -0,0 +1,279 @@
+package impl
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+)
+
+const deviceVerificationPath = "/v1/oauth/device"
+
+// userCodeAlphabet bỏ nguyên âm và các ký tự dễ nhầm (RFC 8628 mục 6.1)
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// DeviceAuthorization bắt đầu device authorization grant (RFC 8628 mục 3.1-3.2)
+func (s *OAuthServerImpl) DeviceAuthorization(ctx context.Context, client *uModels.Client, scope string) (*uModels.DeviceAuthorizationResponse, error) {
+	if !slices.Contains(client.GrantTypes, GrantTypeDeviceCode) {
+		return nil, uModels.NewOAuthError("unauthorized_client", "client is not allowed to use the device code grant")
+	}
+	scopes := strings.Fields(scope)
+	supported := strings.Fields(s.cfg.OAuthScopes)
+	for _, requested := range scopes {
+		if !slices.Contains(supported, requested) || !slices.Contains(client.Scopes, requested) {
+			return nil, uModels.NewOAuthError("invalid_scope", fmt.Sprintf("scope %q is not allowed for this client", requested))
+		}
+	}
+	deviceCode, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate device code: %w", err)
+	}
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate user code: %w", err)
+	}
+	lifetime := time.Duration(s.cfg.DeviceCodeTimeLife) * time.Second
+	now := time.Now()
+	data := &models.DeviceAuthorization{
+		ClientId:  client.ClientId,
+		Scope:     strings.Join(scopes, " "),
+		UserCode:  userCode,
+		Status:    models.DeviceStatusPending,
+		ExpiresAt: now.Add(lifetime),
+		CreatedAt: now,
+	}
+	// device_code giữ lâu gấp đôi để thiết bị poll muộn nhận expired_token thay vì invalid_grant
+	if err := s.repo.Redis().SaveDeviceAuthorization(ctx, deviceCode, data, 2*lifetime, lifetime); err != nil {
+		return nil, err
+	}
+	verificationUri := strings.TrimSuffix(s.cfg.JWTIssuer, "/") + deviceVerificationPath
+	return &uModels.DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                formatUserCode(userCode),
+		VerificationUri:         verificationUri,
+		VerificationUriComplete: verificationUri + "?" + url.Values{"user_code": {formatUserCode(userCode)}}.Encode(),
+		ExpiresIn:               int64(lifetime.Seconds()),
+		Interval:                int64(s.cfg.DeviceCodeInterval),
+	}, nil
+}
+
+// GetDeviceVerification kiểm tra user_code cho trang duyệt. Trình duyệt chưa đăng nhập thì tạo
+// authorization request gắn với user_code để sau khi login quay lại trang duyệt.
+func (s *OAuthServerImpl) GetDeviceVerification(ctx context.Context, ssoSessionId string, userCode string) (*uModels.DeviceVerification, error) {
+	data, err := s.pendingDeviceAuthorization(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.repo.Client().GetClientByClientId(ctx, data.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	result := &uModels.DeviceVerification{
+		UserCode:   formatUserCode(data.UserCode),
+		ClientName: client.Name,
+		Scopes:     strings.Fields(data.Scope),
+	}
+	sso, err := s.ssoSession(ctx, ssoSessionId)
+	if err != nil {
+		return nil, err
+	}
+	if sso != nil {
+		return result, nil
+	}
+
+	requestId, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate authorization request id: %w", err)
+	}
+	err = s.repo.Redis().SaveAuthorizationRequest(ctx, requestId, &models.AuthorizationRequest{
+		ClientId:       data.ClientId,
+		Scope:          data.Scope,
+		DeviceUserCode: data.UserCode,
+		CreatedAt:      time.Now(),
+	}, time.Until(data.ExpiresAt))
+	if err != nil {
+		return nil, err
+	}
+	result.LoginURL = "/v1/oauth/login?" + url.Values{"request_id": {requestId}}.Encode()
+	return result, nil
+}
+
+// ApproveDevice ghi quyết định của user đang đăng nhập (cookie SSO) cho user_code
+func (s *OAuthServerImpl) ApproveDevice(ctx context.Context, ssoSessionId string, userCode string, approve bool, client uModels.ClientInfo) error {
+	sso, err := s.ssoSession(ctx, ssoSessionId)
+	if err != nil {
+		return err
+	}
+	if sso == nil {
+		return uModels.ErrLoginRequired
+	}
+	deviceCode, err := s.repo.Redis().GetDeviceCodeByUserCode(ctx, normalizeUserCode(userCode))
+	if err != nil {
+		return err
+	}
+	if deviceCode == "" {
+		return uModels.ErrInvalidUserCode
+	}
+	data, err := s.repo.Redis().GetDeviceAuthorization(ctx, deviceCode)
+	if err != nil {
+		return err
+	}
+	if data == nil || data.Status != models.DeviceStatusPending || time.Now().After(data.ExpiresAt) {
+		return uModels.ErrInvalidUserCode
+	}
+	data.Status = models.DeviceStatusDenied
+	if approve {
+		data.Status = models.DeviceStatusApproved
+		data.UserId = sso.UserId
+		data.AuthTime = sso.AuthTime
+		data.UserAgent = client.UserAgent
+		data.IPAddress = client.IPAddress
+	}
+	// hai tab duyệt/từ chối cùng lúc thì chỉ quyết định ghi trước được nhận
+	decided, err := s.repo.Redis().DecideDeviceAuthorization(ctx, deviceCode, data)
+	if err != nil {
+		return err
+	}
+	if !decided {
+		return uModels.ErrInvalidUserCode
+	}
+	if approve {
+		// thiết bị được duyệt cũng hiện trong danh sách app đã cấp quyền
+		return grantConsent(ctx, s.repo, sso.UserId, data.ClientId, data.Scope)
+	}
+	return nil
+}
+
+// exchangeDeviceCode xử lý poll của thiết bị ở token endpoint (RFC 8628 mục 3.4-3.5)
+func (s *OAuthServerImpl) exchangeDeviceCode(ctx context.Context, client *uModels.Client, deviceCode string) (*uModels.TokenResponse, error) {
+	if deviceCode == "" {
+		return nil, uModels.NewOAuthError("invalid_request", "device_code is required")
+	}
+	data, err := s.repo.Redis().GetDeviceAuthorization(ctx, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil || data.ClientId != client.ClientId {
+		return nil, uModels.NewOAuthError("invalid_grant", "device code is invalid")
+	}
+	if time.Now().After(data.ExpiresAt) {
+		return nil, uModels.NewOAuthError("expired_token", "device code has expired")
+	}
+	allowed, err := s.repo.Redis().AllowDevicePoll(ctx, deviceCode, time.Duration(s.cfg.DeviceCodeInterval)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, uModels.NewOAuthError("slow_down", "")
+	}
+
+	switch data.Status {
+	case models.DeviceStatusPending:
+		return nil, uModels.NewOAuthError("authorization_pending", "")
+	case models.DeviceStatusDenied:
+		if _, err := s.repo.Redis().ConsumeDeviceAuthorization(ctx, deviceCode); err != nil {
+			return nil, err
+		}
+		return nil, uModels.NewOAuthError("access_denied", "the user denied the request")
+	}
+	// device_code chỉ đổi được một lần, poll song song thì chỉ một request thắng
+	data, err = s.repo.Redis().ConsumeDeviceAuthorization(ctx, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil || data.Status != models.DeviceStatusApproved {
+		return nil, uModels.NewOAuthError("invalid_grant", "device code is invalid")
+	}
+	result, _, err := s.issueUserTokens(ctx, client, userGrant{
+		userId:   data.UserId,
+		scope:    data.Scope,
+		authTime: data.AuthTime,
+		browser: uModels.ClientInfo{
+			UserAgent: data.UserAgent,
+			IPAddress: data.IPAddress,
+		},
+	})
+	return result, err
+}
+
+// pendingDeviceAuthorization tìm yêu cầu đang chờ duyệt theo user_code
+func (s *OAuthServerImpl) pendingDeviceAuthorization(ctx context.Context, userCode string) (*models.DeviceAuthorization, error) {
+	userCode = normalizeUserCode(userCode)
+	if userCode == "" {
+		return nil, uModels.ErrInvalidUserCode
+	}
+	deviceCode, err := s.repo.Redis().GetDeviceCodeByUserCode(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+	if deviceCode == "" {
+		return nil, uModels.ErrInvalidUserCode
+	}
+	data, err := s.repo.Redis().GetDeviceAuthorization(ctx, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil || data.Status != models.DeviceStatusPending || time.Now().After(data.ExpiresAt) {
+		return nil, uModels.ErrInvalidUserCode
+	}
+	return data, nil
+}
+
+// ssoSession trả về nil nếu trình duyệt chưa đăng nhập hoặc user đã bị block
+func (s *OAuthServerImpl) ssoSession(ctx context.Context, ssoSessionId string) (*models.SSOSession, error) {
+	if ssoSessionId == "" {
+		return nil, nil
+	}
+	sso, err := s.repo.Redis().GetSSOSession(ctx, ssoSessionId)
+	if err != nil || sso == nil {
+		return nil, err
+	}
+	if _, err := activeUser(ctx, s.repo, sso.UserId); err != nil {
+		if errors.Is(err, uModels.ErrUserNotActive) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return sso, nil
+}
+
+func generateUserCode() (string, error) {
+	code := make([]byte, 8)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode bỏ dấu gạch, khoảng trắng và không phân biệt hoa thường khi user nhập lại code
+func normalizeUserCode(userCode string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(userCode) {
+		if strings.ContainsRune(userCodeAlphabet, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// formatUserCode hiển thị code dạng XXXX-XXXX cho dễ đọc
+func formatUserCode(userCode string) string {
+	if len(userCode) != 8 {
+		return userCode
+	}
+	return userCode[:4] + "-" + userCode[4:]
+}
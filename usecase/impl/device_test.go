@@ -0,0 +1,95 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+)
+
+// fakeDeviceRedis giữ một device authorization, read trả về bản đọc trước đó để giả lập
+// request khác đã quyết định giữa lúc đọc và lúc ghi
+type fakeDeviceRedis struct {
+	fakeRedis
+	sso    *models.SSOSession
+	stored models.DeviceAuthorization
+	read   models.DeviceAuthorization
+}
+
+func (r *fakeDeviceRedis) GetSSOSession(context.Context, string) (*models.SSOSession, error) {
+	return r.sso, nil
+}
+
+func (r *fakeDeviceRedis) GetDeviceCodeByUserCode(_ context.Context, userCode string) (string, error) {
+	if userCode != r.stored.UserCode {
+		return "", nil
+	}
+	return "device-code", nil
+}
+
+func (r *fakeDeviceRedis) GetDeviceAuthorization(context.Context, string) (*models.DeviceAuthorization, error) {
+	data := r.read
+	return &data, nil
+}
+
+func (r *fakeDeviceRedis) DecideDeviceAuthorization(_ context.Context, _ string, data *models.DeviceAuthorization) (bool, error) {
+	if r.stored.Status != models.DeviceStatusPending {
+		return false, nil
+	}
+	r.stored = *data
+	return true, nil
+}
+
+func TestApproveDeviceDecidesOnce(t *testing.T) {
+	user := &models.User{Id: uuid.New(), Status: uModels.StatusActive}
+	pending := models.DeviceAuthorization{
+		ClientId:  "tv",
+		Scope:     "openid",
+		UserCode:  "BCDFGHJK",
+		Status:    models.DeviceStatusPending,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	tests := []struct {
+		name        string
+		stored      string
+		approve     bool
+		wantErr     error
+		wantStatus  string
+		wantConsent bool
+	}{
+		{name: "approve pending", stored: models.DeviceStatusPending, approve: true, wantStatus: models.DeviceStatusApproved, wantConsent: true},
+		{name: "deny pending", stored: models.DeviceStatusPending, wantStatus: models.DeviceStatusDenied},
+		{name: "approve after concurrent deny", stored: models.DeviceStatusDenied, approve: true, wantErr: uModels.ErrInvalidUserCode, wantStatus: models.DeviceStatusDenied},
+		{name: "deny after concurrent approve", stored: models.DeviceStatusApproved, wantErr: uModels.ErrInvalidUserCode, wantStatus: models.DeviceStatusApproved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redis := &fakeDeviceRedis{
+				sso:  &models.SSOSession{Sid: "sid", UserId: user.Id, AuthTime: time.Now()},
+				read: pending,
+			}
+			redis.stored = pending
+			redis.stored.Status = tt.stored
+			client := &fakeClient{}
+			s := &OAuthServerImpl{cfg: testConfig(t), repo: fakeRepo{
+				auth:   &fakeAuth{users: map[uuid.UUID]*models.User{user.Id: user}},
+				client: client,
+				redis:  redis,
+			}}
+			err := s.ApproveDevice(context.Background(), "cookie", "bcdf-ghjk", tt.approve, uModels.ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApproveDevice() error = %v, want %v", err, tt.wantErr)
+			}
+			if redis.stored.Status != tt.wantStatus {
+				t.Fatalf("status = %q, want %q", redis.stored.Status, tt.wantStatus)
+			}
+			if got := len(client.consents) > 0; got != tt.wantConsent {
+				t.Fatalf("consent recorded = %v, want %v", got, tt.wantConsent)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package impl
+
+import (
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+)
+
+func toIdentity(identity models.UserIdentity) uModels.Identity {
+	return uModels.Identity{
+		Id:            identity.Id,
+		Provider:      identity.Provider,
+		ProviderId:    identity.ProviderId,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		CreatedAt:     identity.CreatedAt,
+	}
+}
+
+func toIdentities(identities []models.UserIdentity) []uModels.Identity {
+	result := make([]uModels.Identity, 0, len(identities))
+	for _, identity := range identities {
+		result = append(result, toIdentity(identity))
+	}
+	return result
+}
+
+func toUser(user *models.User, identities []models.UserIdentity) *uModels.User {
+	return &uModels.User{
+		Id:         user.Id,
+		Email:      user.Email,
+		Name:       user.Name,
+		Status:     user.Status,
+		Avatar:     user.Avatar,
+		Identities: toIdentities(identities),
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
+	}
+}
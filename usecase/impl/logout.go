@@ -0,0 +1,380 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// backchannelLogoutBatch là số job tối đa lấy ra mỗi lần quét hàng đợi
+	backchannelLogoutBatch = 100
+	// backchannelLogoutBackoff là thời gian chờ trước lần gửi lại đầu tiên, nhân đôi sau mỗi lần lỗi
+	backchannelLogoutBackoff = 10 * time.Second
+	// backchannelLogoutConcurrency là số request gửi logout token chạy song song
+	backchannelLogoutConcurrency = 10
+	// backchannelLogoutLease là thời gian giữ job đã nhận, phải đủ gửi hết một batch
+	// (batch / concurrency lần timeout của backchannelLogoutClient)
+	backchannelLogoutLease = 2 * time.Minute
+)
+
+// backchannelLogoutClient không theo redirect (client phải nhận logout token ở đúng URI đã đăng ký),
+// không qua proxy và chỉ kết nối tới IP public để URI của client không gọi được vào mạng nội bộ.
+// privateBackchannelLogoutClient bỏ giới hạn IP, dùng khi bật BACKCHANNEL_LOGOUT_ALLOW_PRIVATE_NETWORKS.
+var (
+	backchannelLogoutClient        = newBackchannelLogoutClient(utils.PublicOnlyDialControl)
+	privateBackchannelLogoutClient = newBackchannelLogoutClient(nil)
+)
+
+func newBackchannelLogoutClient(control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: control}
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConnsPerHost: backchannelLogoutConcurrency,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// EndSession là /v1/oauth/end_session (OIDC RP-Initiated Logout 1.0): kết thúc SSO session của
+// trình duyệt và mọi session đã cấp từ nó, client được báo qua back-channel và front-channel.
+// Lỗi tham số là uModels.ErrInvalidLogoutRequest.
+func (s *OAuthServerImpl) EndSession(ctx context.Context, req uModels.EndSessionRequest) (*uModels.EndSessionResult, error) {
+	var hint *utils.IDTokenClaims
+	if req.IdTokenHint != "" {
+		claims, err := utils.VerifyIDTokenHint(s.cfg, req.IdTokenHint)
+		if err != nil {
+			return nil, fmt.Errorf("%w: id_token_hint is invalid", uModels.ErrInvalidLogoutRequest)
+		}
+		if req.ClientId != "" && !slices.Contains(claims.Audience, req.ClientId) {
+			return nil, fmt.Errorf("%w: client_id does not match id_token_hint", uModels.ErrInvalidLogoutRequest)
+		}
+		hint = claims
+	}
+	clientId := req.ClientId
+	if clientId == "" && hint != nil {
+		clientId = hint.Audience[0]
+	}
+	var client *models.OAuthClient
+	if clientId != "" {
+		var err error
+		client, err = s.authorizationClient(ctx, clientId)
+		if errors.Is(err, uModels.ErrUnknownClient) {
+			return nil, fmt.Errorf("%w: unknown client", uModels.ErrInvalidLogoutRequest)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// post_logout_redirect_uri phải khớp chính xác một URI client đã đăng ký
+	if req.PostLogoutRedirectUri != "" {
+		if client == nil {
+			return nil, fmt.Errorf("%w: post_logout_redirect_uri requires id_token_hint or client_id", uModels.ErrInvalidLogoutRequest)
+		}
+		if !slices.Contains(strings.Fields(client.PostLogoutRedirectUris), req.PostLogoutRedirectUri) {
+			return nil, fmt.Errorf("%w: post_logout_redirect_uri is not registered", uModels.ErrInvalidLogoutRequest)
+		}
+	}
+
+	sso, err := s.ssoSession(ctx, req.SSOSessionId)
+	if err != nil {
+		return nil, err
+	}
+	// không có id_token_hint hợp lệ của đúng user đang đăng nhập thì phải hỏi user,
+	// tránh trang khác đăng xuất user ngoài ý muốn
+	if !req.Confirmed && sso != nil && (hint == nil || hint.Subject != sso.UserId.String()) {
+		result := &uModels.EndSessionResult{ConfirmationRequired: true}
+		if client != nil {
+			result.ClientName = client.Name
+		}
+		return result, nil
+	}
+
+	var sessions []models.Session
+	if sso != nil {
+		if sso.Sid != "" {
+			linked, err := s.repo.Auth().ListActiveSessionsBySSOSid(ctx, sso.Sid)
+			if err != nil {
+				return nil, err
+			}
+			sessions = append(sessions, linked...)
+			if err := s.repo.Redis().DeleteSSOSessionBySid(ctx, sso.Sid); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.repo.Redis().DeleteSSOSession(ctx, req.SSOSessionId); err != nil {
+			return nil, err
+		}
+	}
+	if hint != nil {
+		linked, err := s.hintSessions(ctx, hint)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, linked...)
+	}
+
+	var sessionIds []uuid.UUID
+	for _, session := range sessions {
+		if !slices.Contains(sessionIds, session.Id) {
+			sessionIds = append(sessionIds, session.Id)
+		}
+	}
+	if err := revokeSessions(ctx, s.repo, s.cfg, sessionIds...); err != nil {
+		return nil, err
+	}
+	frontchannel, err := s.frontchannelLogoutUris(ctx, sessions)
+	if err != nil {
+		return nil, err
+	}
+	result := &uModels.EndSessionResult{FrontchannelLogoutUris: frontchannel}
+	if req.PostLogoutRedirectUri != "" {
+		result.RedirectURL = postLogoutRedirect(req.PostLogoutRedirectUri, req.State)
+	}
+	return result, nil
+}
+
+// hintSessions trả về session trong sid của id_token_hint và các session cùng phiên đăng nhập
+// trình duyệt với nó, để đăng xuất được cả khi request không kèm cookie SSO
+func (s *OAuthServerImpl) hintSessions(ctx context.Context, hint *utils.IDTokenClaims) ([]models.Session, error) {
+	sessionId, err := uuid.Parse(hint.SessionId)
+	if err != nil {
+		return nil, nil
+	}
+	session, err := s.repo.Auth().GetSessionById(ctx, sessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if session.RevokedAt != nil || session.UserId.String() != hint.Subject || !slices.Contains(hint.Audience, session.ClientId) {
+		return nil, nil
+	}
+	if session.SSOSid == "" {
+		return []models.Session{*session}, nil
+	}
+	if err := s.repo.Redis().DeleteSSOSessionBySid(ctx, session.SSOSid); err != nil {
+		return nil, err
+	}
+	return s.repo.Auth().ListActiveSessionsBySSOSid(ctx, session.SSOSid)
+}
+
+// frontchannelLogoutUris là frontchannel_logout_uri kèm iss và sid (OIDC Front-Channel Logout mục 2)
+// của các client có session vừa bị thu hồi
+func (s *OAuthServerImpl) frontchannelLogoutUris(ctx context.Context, sessions []models.Session) ([]string, error) {
+	var uris []string
+	for _, session := range sessions {
+		if session.ClientId == "" {
+			continue
+		}
+		client, err := s.repo.Client().GetClientByClientId(ctx, session.ClientId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if client.FrontchannelLogoutUri == "" {
+			continue
+		}
+		u, err := url.Parse(client.FrontchannelLogoutUri)
+		if err != nil {
+			continue
+		}
+		query := u.Query()
+		query.Set("iss", s.cfg.JWTIssuer)
+		query.Set("sid", session.Id.String())
+		u.RawQuery = query.Encode()
+		if uri := u.String(); !slices.Contains(uris, uri) {
+			uris = append(uris, uri)
+		}
+	}
+	return uris, nil
+}
+
+func postLogoutRedirect(redirectUri string, state string) string {
+	if state == "" {
+		return redirectUri
+	}
+	u, err := url.Parse(redirectUri)
+	if err != nil {
+		return redirectUri
+	}
+	query := u.Query()
+	query.Set("state", state)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// enqueueBackchannelLogout đưa session vừa bị thu hồi vào hàng đợi nếu client của session
+// có backchannel_logout_uri, logout token được gửi bởi WatchBackchannelLogouts
+func enqueueBackchannelLogout(ctx context.Context, repo rInterfaces.Repo, sessionId uuid.UUID) error {
+	session, err := repo.Auth().GetSessionById(ctx, sessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if session.ClientId == "" {
+		return nil
+	}
+	client, err := repo.Client().GetClientByClientId(ctx, session.ClientId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if client.BackchannelLogoutUri == "" {
+		return nil
+	}
+	now := time.Now()
+	return repo.Redis().EnqueueBackchannelLogout(ctx, &models.BackchannelLogoutJob{
+		Id:        uuid.NewString(),
+		ClientId:  session.ClientId,
+		UserId:    session.UserId,
+		SessionId: session.Id,
+		CreatedAt: now,
+	}, now)
+}
+
+// WatchBackchannelLogouts gửi logout token trong hàng đợi định kỳ cho tới khi ctx bị huỷ.
+// Nhiều instance có thể chạy cùng lúc, mỗi job chỉ được một instance nhận.
+func (s *OAuthServerImpl) WatchBackchannelLogouts(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.sendBackchannelLogouts(ctx); err != nil {
+				log.Printf("failed to send backchannel logouts: %v", err)
+			}
+		}
+	}
+}
+
+func (s *OAuthServerImpl) sendBackchannelLogouts(ctx context.Context) error {
+	for {
+		jobs, err := s.repo.Redis().ClaimBackchannelLogouts(ctx, time.Now(), backchannelLogoutLease, backchannelLogoutBatch)
+		if err != nil {
+			return err
+		}
+		var (
+			wg       sync.WaitGroup
+			mu       sync.Mutex
+			firstErr error
+		)
+		sem := make(chan struct{}, backchannelLogoutConcurrency)
+		for i := range jobs {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(job *models.BackchannelLogoutJob) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				if err := s.processBackchannelLogout(ctx, job); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}(&jobs[i])
+		}
+		wg.Wait()
+		if firstErr != nil {
+			return firstErr
+		}
+		if len(jobs) < backchannelLogoutBatch {
+			return nil
+		}
+	}
+}
+
+// processBackchannelLogout gửi một job: client nhận (2xx) hoặc hết số lần thử thì xoá khỏi hàng đợi,
+// còn lại hẹn gửi lại với backoff. Lỗi trả về chỉ là lỗi của hàng đợi.
+func (s *OAuthServerImpl) processBackchannelLogout(ctx context.Context, job *models.BackchannelLogoutJob) error {
+	err := s.deliverBackchannelLogout(ctx, job)
+	if err == nil {
+		return s.repo.Redis().AckBackchannelLogout(ctx, job)
+	}
+	job.Attempt++
+	if job.Attempt >= int(s.cfg.BackchannelLogoutMaxAttempts) {
+		log.Printf("backchannel logout to client %s for session %s failed after %d attempts: %v", job.ClientId, job.SessionId, job.Attempt, err)
+		return s.repo.Redis().AckBackchannelLogout(ctx, job)
+	}
+	retryAt := time.Now().Add(backchannelLogoutBackoff << (job.Attempt - 1))
+	return s.repo.Redis().RetryBackchannelLogout(ctx, job, retryAt)
+}
+
+// deliverBackchannelLogout POST logout token tới client (OIDC Back-Channel Logout mục 2.5),
+// client trả 2xx là đã nhận. Token được ký lại ở mỗi lần gửi vì chỉ sống vài phút.
+func (s *OAuthServerImpl) deliverBackchannelLogout(ctx context.Context, job *models.BackchannelLogoutJob) error {
+	client, err := s.repo.Client().GetClientByClientId(ctx, job.ClientId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	// client đã bỏ backchannel_logout_uri thì không còn ai để báo
+	if client.BackchannelLogoutUri == "" {
+		return nil
+	}
+	token, err := utils.GenerateLogoutToken(s.cfg, utils.LogoutTokenParams{
+		UserId:    job.UserId,
+		ClientId:  job.ClientId,
+		SessionId: job.SessionId,
+	})
+	if err != nil {
+		return err
+	}
+	form := url.Values{"logout_token": {token}}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, client.BackchannelLogoutUri, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpClient := backchannelLogoutClient
+	if s.cfg.BackchannelLogoutAllowPrivateNetworks {
+		httpClient = privateBackchannelLogoutClient
+	}
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("client responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
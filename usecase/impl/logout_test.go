@@ -0,0 +1,113 @@
+package impl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/johnquangdev/oauth2/repository/models"
+)
+
+// fakeQueue ghi lại job được Ack/Retry thay cho hàng đợi redis
+type fakeQueue struct {
+	fakeRedis
+	acked   []string
+	retried []*models.BackchannelLogoutJob
+	retryAt []time.Time
+}
+
+func (q *fakeQueue) AckBackchannelLogout(_ context.Context, job *models.BackchannelLogoutJob) error {
+	q.acked = append(q.acked, job.Member)
+	return nil
+}
+
+func (q *fakeQueue) RetryBackchannelLogout(_ context.Context, job *models.BackchannelLogoutJob, at time.Time) error {
+	q.retried = append(q.retried, job)
+	q.retryAt = append(q.retryAt, at)
+	return nil
+}
+
+func TestProcessBackchannelLogout(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		attempt     int
+		wantAcked   bool
+		wantAttempt int
+	}{
+		{name: "client accepted", status: http.StatusOK, wantAcked: true},
+		{name: "client failed is retried", status: http.StatusInternalServerError, wantAttempt: 1},
+		{name: "redirect is a failure", status: http.StatusFound, attempt: 1, wantAttempt: 2},
+		{name: "last attempt failed is dropped", status: http.StatusServiceUnavailable, attempt: 2, wantAcked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.PostFormValue("logout_token")
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			cfg := testConfig(t)
+			cfg.BackchannelLogoutMaxAttempts = 3
+			// httptest chạy trên loopback
+			cfg.BackchannelLogoutAllowPrivateNetworks = true
+			queue := &fakeQueue{}
+			s := &OAuthServerImpl{cfg: cfg, repo: fakeRepo{
+				client: &fakeClient{clients: map[string]*models.OAuthClient{
+					"app": {ClientId: "app", BackchannelLogoutUri: server.URL},
+				}},
+				redis: queue,
+			}}
+			job := &models.BackchannelLogoutJob{
+				Id: uuid.NewString(), ClientId: "app", UserId: uuid.New(), SessionId: uuid.New(),
+				Attempt: tt.attempt, Member: "member",
+			}
+			if err := s.processBackchannelLogout(context.Background(), job); err != nil {
+				t.Fatal(err)
+			}
+			if got == "" {
+				t.Fatal("logout_token was not delivered")
+			}
+			if tt.wantAcked {
+				if len(queue.acked) != 1 || queue.acked[0] != "member" || len(queue.retried) != 0 {
+					t.Fatalf("acked = %v, retried = %d, want the job acked", queue.acked, len(queue.retried))
+				}
+				return
+			}
+			if len(queue.acked) != 0 || len(queue.retried) != 1 {
+				t.Fatalf("acked = %v, retried = %d, want the job retried", queue.acked, len(queue.retried))
+			}
+			if queue.retried[0].Attempt != tt.wantAttempt || queue.retried[0].Member != "member" {
+				t.Fatalf("retried job = %+v, want attempt %d of the claimed member", queue.retried[0], tt.wantAttempt)
+			}
+			if !queue.retryAt[0].After(time.Now()) {
+				t.Fatalf("retry at %v is not in the future", queue.retryAt[0])
+			}
+		})
+	}
+}
+
+func TestDeliverBackchannelLogoutRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	s := &OAuthServerImpl{cfg: testConfig(t), repo: fakeRepo{
+		client: &fakeClient{clients: map[string]*models.OAuthClient{
+			"app": {ClientId: "app", BackchannelLogoutUri: server.URL},
+		}},
+	}}
+	err := s.deliverBackchannelLogout(context.Background(), &models.BackchannelLogoutJob{
+		ClientId: "app", UserId: uuid.New(), SessionId: uuid.New(),
+	})
+	if err == nil || called {
+		t.Fatalf("deliverBackchannelLogout() error = %v, called = %v, want the loopback connection refused", err, called)
+	}
+}
@@ -0,0 +1,238 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
+	"github.com/johnquangdev/oauth2/service/provider"
+	"github.com/johnquangdev/oauth2/usecase/interfaces"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+// OAuth2Impl là luồng login chung cho mọi provider trong registry
+type OAuth2Impl struct {
+	repo      rInterfaces.Repo
+	cfg       utils.Config
+	providers *provider.Registry
+}
+
+func NewOAuth2(cfg utils.Config, r rInterfaces.Repo, providers *provider.Registry) interfaces.OAuth2 {
+	return &OAuth2Impl{
+		repo:      r,
+		cfg:       cfg,
+		providers: providers,
+	}
+}
+
+func (o *OAuth2Impl) provider(name string) (provider.Provider, error) {
+	p, ok := o.providers.Get(name)
+	if !ok {
+		return nil, uModels.ErrProviderNotFound
+	}
+	return p, nil
+}
+
+func (o *OAuth2Impl) Providers() []string {
+	return o.providers.Names()
+}
+
+// GetAuthURL bắt đầu login qua provider. authorizationRequestId khác rỗng khi login là bước
+// xác thực user của /v1/oauth/authorize.
+func (o *OAuth2Impl) GetAuthURL(ctx context.Context, providerName string, binding string, authorizationRequestId string) (string, error) {
+	if authorizationRequestId != "" {
+		authzReq, err := o.repo.Redis().GetAuthorizationRequest(ctx, authorizationRequestId)
+		if err != nil {
+			return "", err
+		}
+		if authzReq == nil {
+			return "", uModels.ErrInvalidAuthzRequest
+		}
+	}
+	return o.authURL(ctx, providerName, &models.OAuthState{
+		Binding:                binding,
+		AuthorizationRequestId: authorizationRequestId,
+	})
+}
+
+// GetLinkURL bắt đầu login với provider khác để liên kết vào user đang đăng nhập
+func (o *OAuth2Impl) GetLinkURL(ctx context.Context, providerName string, binding string, userId uuid.UUID) (string, error) {
+	return o.authURL(ctx, providerName, &models.OAuthState{
+		Binding:    binding,
+		LinkUserId: &userId,
+	})
+}
+
+func (o *OAuth2Impl) authURL(ctx context.Context, providerName string, data *models.OAuthState) (string, error) {
+	p, err := o.provider(providerName)
+	if err != nil {
+		return "", err
+	}
+	state, err := utils.GenerateRandomString(16)
+	if err != nil {
+		return "", fmt.Errorf("cannot generate %s auth url: %w", providerName, err)
+	}
+	nonce, err := utils.GenerateRandomString(16)
+	if err != nil {
+		return "", fmt.Errorf("cannot generate %s auth url: %w", providerName, err)
+	}
+	// PKCE: verifier lưu cùng state, chỉ code_challenge được gửi lên provider
+	data.Provider = p.Name()
+	data.CodeVerifier = oauth2.GenerateVerifier()
+	data.Nonce = nonce
+	if err := saveOAuthState(ctx, o.repo, o.cfg, state, data); err != nil {
+		return "", err
+	}
+	url, err := p.AuthCodeURL(ctx, provider.AuthRequest{
+		State:        state,
+		CodeVerifier: data.CodeVerifier,
+		Nonce:        data.Nonce,
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot generate %s auth url: %w", providerName, err)
+	}
+	return url, nil
+}
+
+func (o *OAuth2Impl) Login(ctx context.Context, providerName string, req uModels.ExchangeTokenRequest) (*uModels.LoginResult, error) {
+	p, err := o.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	// validate code
+	if req.Code == "" {
+		return nil, fmt.Errorf("code is required")
+	}
+
+	// verify state (single-use, gắn với trình duyệt đã bắt đầu login)
+	oauthState, err := consumeOAuthState(ctx, o.repo, p.Name(), req)
+	if err != nil {
+		return nil, err
+	}
+	authReq := provider.AuthRequest{
+		State:        req.State,
+		CodeVerifier: oauthState.CodeVerifier,
+		Nonce:        oauthState.Nonce,
+	}
+
+	// Exchange code for token
+	token, err := p.Exchange(ctx, req.Code, authReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
+	}
+
+	// get identity from provider
+	identity, err := p.FetchIdentity(ctx, token, authReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if oauthState.LinkUserId != nil {
+		linked, err := o.linkIdentity(ctx, *oauthState.LinkUserId, identity)
+		if err != nil {
+			return nil, err
+		}
+		return &uModels.LoginResult{LinkedIdentity: linked}, nil
+	}
+
+	//Check userExist
+	userExist, err := o.repo.Auth().GetUserByProviderAndProviderId(ctx, identity.Provider, identity.Subject)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// identity mới: thử gắn vào tài khoản có sẵn theo email đã xác minh (nếu bật)
+		var pending *uModels.PendingLink
+		userExist, pending, err = o.mergeByEmail(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+		if pending != nil {
+			return &uModels.LoginResult{PendingLink: pending}, nil
+		}
+		if userExist == nil {
+			err = gorm.ErrRecordNotFound
+		}
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			userExist = &models.User{
+				Id:     uuid.New(),
+				Email:  identity.Email,
+				Name:   identity.Name,
+				Avatar: identity.Avatar,
+				Status: uModels.StatusPending,
+			}
+			if err := o.repo.Auth().CreateUserWithIdentity(ctx, userExist, newUserIdentity(identity)); err != nil {
+				return nil, fmt.Errorf("create user error: %w", err)
+			}
+		} else {
+			return nil, err
+		}
+	}
+	// user bị block/ban không được tạo session mới, kể cả khi đăng nhập lại qua provider
+	if userExist.Status == uModels.StatusBlocked || userExist.Status == uModels.StatusBanned {
+		return nil, uModels.ErrUserNotActive
+	}
+	if oauthState.AuthorizationRequestId != "" {
+		return completeAuthorization(ctx, o.repo, o.cfg, oauthState.AuthorizationRequestId, userExist, req.Client)
+	}
+	identities, err := o.repo.Auth().ListIdentitiesByUserId(ctx, userExist.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// create session + JWT (access + refresh token)
+	tokens, err := createSession(ctx, o.repo, o.cfg, userExist, &models.Session{
+		UserAgent: req.Client.UserAgent,
+		IPAddress: req.Client.IPAddress,
+		Device:    utils.ParseUserAgent(req.Client.UserAgent).String(),
+	}, defaultTokenLifetime(o.cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	return &uModels.LoginResult{
+		Token: tokens.toTokenJwt(),
+		User:  toUser(userExist, identities),
+	}, nil
+}
+
+// linkIdentity gắn identity vào user, từ chối nếu identity đang thuộc user khác
+func (o *OAuth2Impl) linkIdentity(ctx context.Context, userId uuid.UUID, identity *provider.Identity) (*uModels.Identity, error) {
+	existing, err := o.repo.Auth().GetIdentityByProviderAndProviderId(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		if existing.UserId != userId {
+			return nil, uModels.ErrIdentityLinked
+		}
+		linked := toIdentity(*existing)
+		return &linked, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	userIdentity := newUserIdentity(identity)
+	userIdentity.UserId = userId
+	if err := o.repo.Auth().CreateIdentity(ctx, userIdentity); err != nil {
+		return nil, fmt.Errorf("create identity error: %w", err)
+	}
+	if err := recordLinkAudit(ctx, o.repo, &userId, identity.Provider, identity.Subject, identity.Email, models.LinkDecisionLinked); err != nil {
+		return nil, err
+	}
+	linked := toIdentity(*userIdentity)
+	return &linked, nil
+}
+
+func newUserIdentity(identity *provider.Identity) *models.UserIdentity {
+	return &models.UserIdentity{
+		Id:            uuid.New(),
+		Provider:      identity.Provider,
+		ProviderId:    identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+	}
+}
@@ -0,0 +1,208 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/usecase/interfaces"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// dummySecretHash dùng khi client_id không tồn tại để thời gian phản hồi giống như sai secret
+var dummySecretHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy-client-secret"), bcrypt.DefaultCost)
+	return hash
+})
+
+// OAuthServerImpl là phần authorization server phục vụ OAuth client
+type OAuthServerImpl struct {
+	repo rInterfaces.Repo
+	cfg  utils.Config
+}
+
+func NewOAuthServer(cfg utils.Config, repo rInterfaces.Repo) interfaces.OAuthServer {
+	return &OAuthServerImpl{
+		repo: repo,
+		cfg:  cfg,
+	}
+}
+
+// AuthenticateClient xác thực client bằng client_id + secret. Client public chỉ gửi client_id
+// và không được gửi secret; việc chứng minh quyền sở hữu code dựa vào PKCE.
+func (s *OAuthServerImpl) AuthenticateClient(ctx context.Context, clientId string, clientSecret string) (*uModels.Client, error) {
+	if clientId == "" {
+		return nil, uModels.ErrInvalidClient
+	}
+	client, err := s.repo.Client().GetClientByClientId(ctx, clientId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_ = bcrypt.CompareHashAndPassword(dummySecretHash(), []byte(clientSecret))
+			return nil, uModels.ErrInvalidClient
+		}
+		return nil, err
+	}
+	if client.DisabledAt != nil {
+		return nil, uModels.ErrInvalidClient
+	}
+	if client.IsPublic {
+		if clientSecret != "" {
+			return nil, uModels.ErrInvalidClient
+		}
+	} else if clientSecret == "" ||
+		bcrypt.CompareHashAndPassword([]byte(client.ClientSecretHash), []byte(clientSecret)) != nil {
+		return nil, uModels.ErrInvalidClient
+	}
+	result := toClient(client)
+	return &result, nil
+}
+
+// Introspect trả về trạng thái token theo RFC 7662. Token chỉ active khi chữ ký và claims hợp lệ,
+// jti không bị thu hồi, session còn hiệu lực và user không bị block/ban.
+func (s *OAuthServerImpl) Introspect(ctx context.Context, token string) (*uModels.Introspection, error) {
+	inactive := &uModels.Introspection{Active: false}
+
+	tokenType := "access_token"
+	claims, err := utils.VerifyIssuedToken(token, s.cfg, utils.TokenTypeAccess)
+	if err != nil {
+		tokenType = "refresh_token"
+		claims, err = utils.VerifyIssuedToken(token, s.cfg, utils.TokenTypeRefresh)
+	}
+	if err != nil {
+		return inactive, nil
+	}
+	return s.introspectClaims(ctx, token, tokenType, claims)
+}
+
+// introspectClaims kiểm tra token đã verify chữ ký còn hiệu lực không: jti chưa bị thu hồi,
+// client chưa bị vô hiệu hoá, session và user còn hoạt động
+func (s *OAuthServerImpl) introspectClaims(ctx context.Context, token string, tokenType string, claims *utils.TokenClaims) (*uModels.Introspection, error) {
+	inactive := &uModels.Introspection{Active: false}
+
+	revoked, err := isTokenRevoked(ctx, s.repo, claims.Id, claims.ID, claims.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return inactive, nil
+	}
+
+	result := &uModels.Introspection{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientId:  claims.ClientId,
+		TokenType: tokenType,
+		Sub:       claims.Subject,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+		Act:       toActor(claims.Act),
+	}
+	if claims.ExpiresAt != nil {
+		result.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		result.Iat = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		result.Nbf = claims.NotBefore.Unix()
+	}
+
+	// token client_credentials không có user hay session, chỉ còn hiệu lực khi client chưa bị vô hiệu hoá
+	if claims.IsClient() {
+		client, err := s.repo.Client().GetClientByClientId(ctx, claims.ClientId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return inactive, nil
+			}
+			return nil, err
+		}
+		if client.DisabledAt != nil {
+			return inactive, nil
+		}
+		return result, nil
+	}
+
+	if claims.SessionId != uuid.Nil {
+		session, err := s.repo.Auth().GetSessionById(ctx, claims.SessionId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return inactive, nil
+			}
+			return nil, err
+		}
+		if session.UserId != claims.Id || session.RevokedAt != nil || session.IsBlocked ||
+			session.RefreshTokenExpiresAt.Before(time.Now()) {
+			return inactive, nil
+		}
+		// refresh token đã bị rotate thì không còn dùng được
+		if tokenType == "refresh_token" && session.RefreshToken != token {
+			return inactive, nil
+		}
+		result.SessionId = session.Id.String()
+		result.SessionExpiresAt = session.RefreshTokenExpiresAt.Unix()
+	}
+
+	user, err := s.repo.Auth().GetUserByUserId(ctx, claims.Id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	if user.Status == uModels.StatusBlocked || user.Status == uModels.StatusBanned {
+		return inactive, nil
+	}
+	result.Username = user.Email
+	return result, nil
+}
+
+// Revoke thu hồi token theo RFC 7009: refresh token thu hồi cả session (kèm access token của session),
+// access token chỉ bị thu hồi theo jti. Token không hợp lệ, đã thu hồi hoặc thuộc client khác
+// được bỏ qua mà không báo lỗi để không lộ thông tin về token.
+func (s *OAuthServerImpl) Revoke(ctx context.Context, client *uModels.Client, token string, tokenTypeHint string) error {
+	// hint chỉ quyết định thứ tự thử, hint không hợp lệ thì bỏ qua
+	types := []string{utils.TokenTypeAccess, utils.TokenTypeRefresh}
+	if tokenTypeHint == "refresh_token" {
+		types = []string{utils.TokenTypeRefresh, utils.TokenTypeAccess}
+	}
+	for _, tokenType := range types {
+		claims, err := utils.VerifyIssuedToken(token, s.cfg, tokenType)
+		if err != nil {
+			continue
+		}
+		// token phát hành cho client khác thì client này không được thu hồi;
+		// token của first-party app (không có client_id) thì ai giữ token cũng thu hồi được
+		if claims.ClientId != "" && claims.ClientId != client.ClientId {
+			return nil
+		}
+		if tokenType == utils.TokenTypeAccess {
+			return blacklistToken(ctx, s.repo, claims.ID, claims.ExpiresAt)
+		}
+		return s.revokeRefreshToken(ctx, claims.Id, claims.SessionId)
+	}
+	return nil
+}
+
+func (s *OAuthServerImpl) revokeRefreshToken(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) error {
+	if sessionId == uuid.Nil {
+		return nil
+	}
+	session, err := s.repo.Auth().GetSessionById(ctx, sessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if session.UserId != userId || session.RevokedAt != nil {
+		return nil
+	}
+	return revokeSessions(ctx, s.repo, s.cfg, session.Id)
+}
@@ -0,0 +1,44 @@
+package impl
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+)
+
+// saveOAuthState lưu state vào redis, gắn với provider và cookie binding của trình duyệt
+func saveOAuthState(ctx context.Context, repo rInterfaces.Repo, cfg utils.Config, state string, data *models.OAuthState) error {
+	if data.Binding == "" {
+		return fmt.Errorf("state binding is required")
+	}
+	data.CreatedAt = time.Now().UTC()
+	ttl := time.Duration(cfg.OAuthStateTimeLife) * time.Minute
+	if err := repo.Redis().SaveOAuthState(ctx, state, data, ttl); err != nil {
+		return fmt.Errorf("save oauth state error: %w", err)
+	}
+	return nil
+}
+
+// consumeOAuthState kiểm tra state từ callback: phải tồn tại, chưa dùng, đúng provider và đúng trình duyệt
+func consumeOAuthState(ctx context.Context, repo rInterfaces.Repo, provider string, req uModels.ExchangeTokenRequest) (*models.OAuthState, error) {
+	if req.State == "" || req.Binding == "" {
+		return nil, uModels.ErrMissingState
+	}
+	data, err := repo.Redis().ConsumeOAuthState(ctx, req.State)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil || data.Provider != provider {
+		return nil, uModels.ErrInvalidState
+	}
+	if subtle.ConstantTimeCompare([]byte(data.Binding), []byte(req.Binding)) != 1 {
+		return nil, uModels.ErrInvalidState
+	}
+	return data, nil
+}
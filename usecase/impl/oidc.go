@@ -0,0 +1,134 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"gorm.io/gorm"
+)
+
+// UserInfo trả về claims của user theo scope của access token (OIDC Core mục 5.3).
+// Token phải là access token còn hiệu lực, cấp cho API này hoặc cho chính client của token
+// (token đã đổi sang audience khác qua token exchange bị từ chối) và có scope openid.
+func (s *OAuthServerImpl) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	tokenClaims, err := utils.VerifyIssuedToken(accessToken, s.cfg, utils.TokenTypeAccess)
+	if err != nil {
+		return nil, uModels.ErrInvalidToken
+	}
+	if !userInfoAudience(s.cfg, tokenClaims) {
+		return nil, uModels.ErrInvalidToken
+	}
+	token, err := s.introspectClaims(ctx, accessToken, "access_token", tokenClaims)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Active {
+		return nil, uModels.ErrInvalidToken
+	}
+	scopes := strings.Fields(token.Scope)
+	if !slices.Contains(scopes, ScopeOpenId) {
+		return nil, uModels.ErrInsufficientScope
+	}
+	userId, err := uuid.Parse(token.Sub)
+	if err != nil {
+		return nil, uModels.ErrInvalidToken
+	}
+	user, err := s.repo.Auth().GetUserByUserId(ctx, userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, uModels.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	claims := map[string]interface{}{
+		"sub": user.Id.String(),
+	}
+	if slices.Contains(scopes, ScopeProfile) {
+		claims["name"] = user.Name
+		if user.Avatar != "" {
+			claims["picture"] = user.Avatar
+		}
+		claims["updated_at"] = user.UpdatedAt.Unix()
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		identities, err := s.repo.Auth().ListIdentitiesByUserId(ctx, user.Id)
+		if err != nil {
+			return nil, err
+		}
+		// email được xác minh khi có ít nhất một provider xác nhận email này
+		verified := false
+		for _, identity := range identities {
+			if identity.EmailVerified && strings.EqualFold(identity.Email, user.Email) {
+				verified = true
+				break
+			}
+		}
+		claims["email"] = user.Email
+		claims["email_verified"] = verified
+	}
+	return claims, nil
+}
+
+// userInfoAudience: aud phải chứa JWT_AUDIENCE hoặc client_id của chính token
+func userInfoAudience(cfg utils.Config, claims *utils.TokenClaims) bool {
+	if slices.Contains(claims.Audience, cfg.JWTAudience) {
+		return true
+	}
+	return claims.ClientId != "" && slices.Contains(claims.Audience, claims.ClientId)
+}
+
+// Metadata là discovery document cho /.well-known/openid-configuration và
+// /.well-known/oauth-authorization-server, mọi endpoint tính từ JWT_ISSUER
+func (s *OAuthServerImpl) Metadata() uModels.ServerMetadata {
+	issuer := strings.TrimSuffix(s.cfg.JWTIssuer, "/")
+	authMethods := []string{AuthMethodClientSecretBasic, AuthMethodClientSecretPost, AuthMethodNone}
+	metadata := uModels.ServerMetadata{
+		Issuer:                             s.cfg.JWTIssuer,
+		AuthorizationEndpoint:              issuer + "/v1/oauth/authorize",
+		TokenEndpoint:                      issuer + "/v1/oauth/token",
+		UserinfoEndpoint:                   issuer + "/v1/oauth/userinfo",
+		JwksUri:                            issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:              issuer + "/v1/oauth/introspect",
+		RevocationEndpoint:                 issuer + "/v1/oauth/revoke",
+		DeviceAuthorizationEndpoint:        issuer + "/v1/oauth/device_authorization",
+		PushedAuthorizationRequestEndpoint: issuer + "/v1/oauth/par",
+		RequirePushedAuthorizationRequests: s.cfg.RequirePushedAuthorizationRequests,
+		RequestParameterSupported:          true,
+		// request_uri chỉ nhận giá trị do /v1/oauth/par cấp, không tải request object từ URL
+		RequestUriParameterSupported:           false,
+		RequestObjectSigningAlgValuesSupported: requestObjectSigningAlgs,
+		ScopesSupported:                        strings.Fields(s.cfg.OAuthScopes),
+		ResponseTypesSupported:                 []string{"code"},
+		ResponseModesSupported:                 []string{"query"},
+		GrantTypesSupported:                    supportedGrantTypes,
+		SubjectTypesSupported:                  []string{"public"},
+		IdTokenSigningAlgValuesSupported:       s.cfg.JWTKeys.Algorithms(),
+		TokenEndpointAuthMethodsSupported:      authMethods,
+		// introspection chỉ dành cho client confidential
+		IntrospectionEndpointAuthMethodsSupported: []string{AuthMethodClientSecretBasic, AuthMethodClientSecretPost},
+		RevocationEndpointAuthMethodsSupported:    authMethods,
+		CodeChallengeMethodsSupported:             []string{"S256"},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "acr", "amr", "azp", "sid", "at_hash",
+			"name", "picture", "updated_at", "email", "email_verified",
+		},
+		AcrValuesSupported:                         []string{AcrFederated},
+		PromptValuesSupported:                      []string{"none", "login", "consent"},
+		AuthorizationResponseIssParameterSupported: true,
+		EndSessionEndpoint:                         issuer + "/v1/oauth/end_session",
+		BackchannelLogoutSupported:                 true,
+		BackchannelLogoutSessionSupported:          true,
+		FrontchannelLogoutSupported:                true,
+		FrontchannelLogoutSessionSupported:         true,
+	}
+	if s.cfg.DynamicClientRegistration {
+		metadata.RegistrationEndpoint = issuer + "/v1/oauth/register"
+	}
+	return metadata
+}
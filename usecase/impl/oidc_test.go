@@ -0,0 +1,96 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+)
+
+func TestUserInfoAudience(t *testing.T) {
+	cfg := testConfig(t)
+	tests := []struct {
+		name     string
+		clientId string
+		audience []string
+		want     bool
+	}{
+		{name: "api audience", audience: []string{cfg.JWTAudience}, want: true},
+		{name: "client session token", clientId: "app", audience: []string{"app"}, want: true},
+		{name: "client token with api audience", clientId: "app", audience: []string{cfg.JWTAudience, "app"}, want: true},
+		{name: "exchanged for another service", clientId: "app", audience: []string{"reports-api"}},
+		{name: "another client's audience", clientId: "app", audience: []string{"other-app"}},
+		{name: "first-party token for another service", audience: []string{"reports-api"}},
+		{name: "no audience"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &utils.TokenClaims{ClientId: tt.clientId}
+			claims.Audience = jwt.ClaimStrings(tt.audience)
+			if got := userInfoAudience(cfg, claims); got != tt.want {
+				t.Fatalf("userInfoAudience() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserInfoRejectsExchangedToken(t *testing.T) {
+	cfg := testConfig(t)
+	token, _, err := utils.GenerateToken(cfg, utils.TokenParams{
+		Type:      utils.TokenTypeAccess,
+		UserId:    uuid.New(),
+		SessionId: uuid.New(),
+		ClientId:  "app",
+		Scope:     "openid profile",
+		Audience:  []string{"reports-api"},
+		Act:       &utils.ActorClaim{Subject: "app"},
+		TimeLife:  time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// repo rỗng: token phải bị từ chối trước khi tra session hay user
+	s := &OAuthServerImpl{cfg: cfg, repo: fakeRepo{}}
+	if _, err := s.UserInfo(context.Background(), token); !errors.Is(err, uModels.ErrInvalidToken) {
+		t.Fatalf("UserInfo() error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestExchangeScope(t *testing.T) {
+	client := &uModels.Client{Scopes: []string{"openid", "profile", "reports:read"}}
+	tests := []struct {
+		name         string
+		subjectScope string
+		requested    string
+		want         string
+		wantErr      bool
+	}{
+		{name: "defaults to the intersection", subjectScope: "openid profile email", want: "openid profile"},
+		{name: "narrower request", subjectScope: "openid profile", requested: "profile", want: "profile"},
+		{name: "first-party token is limited by the client", want: "openid profile reports:read"},
+		{name: "first-party token narrower request", requested: "reports:read", want: "reports:read"},
+		{name: "scope not in subject token", subjectScope: "openid", requested: "profile", wantErr: true},
+		{name: "scope not allowed for client", subjectScope: "openid email", requested: "email", wantErr: true},
+		{name: "no common scope", subjectScope: "email", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := exchangeScope(client, tt.subjectScope, tt.requested)
+			if tt.wantErr {
+				var oauthErr *uModels.OAuthError
+				if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_scope" {
+					t.Fatalf("exchangeScope() error = %v, want invalid_scope", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("exchangeScope() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
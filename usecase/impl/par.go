@@ -0,0 +1,76 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+)
+
+// parRequestUriPrefix là tiền tố request_uri theo RFC 9126 mục 2.2
+const parRequestUriPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// PushAuthorizationRequest nhận tham số authorization trực tiếp từ client đã xác thực (RFC 9126),
+// kiểm tra như /v1/oauth/authorize rồi lưu vào redis và trả về request_uri dùng một lần.
+// Mọi lỗi là *uModels.OAuthError, uModels.ErrInvalidRedirectUri hoặc uModels.ErrInvalidRequestObject.
+func (s *OAuthServerImpl) PushAuthorizationRequest(ctx context.Context, client *uModels.Client, req uModels.AuthorizeRequest) (*uModels.PushedAuthorizationResponse, error) {
+	if req.RequestUri != "" {
+		return nil, uModels.NewOAuthError("invalid_request", "request_uri is not allowed in a pushed authorization request")
+	}
+	oauthClient, err := s.authorizationClient(ctx, client.ClientId)
+	if err != nil {
+		if errors.Is(err, uModels.ErrUnknownClient) {
+			return nil, uModels.NewOAuthError("unauthorized_client", "client is disabled")
+		}
+		return nil, err
+	}
+	signed := req.Request != ""
+	if signed {
+		if req, err = s.applyRequestObject(oauthClient, req); err != nil {
+			return nil, err
+		}
+	}
+	redirectUri, err := resolveRedirectUri(oauthClient, req.RedirectUri)
+	if err != nil {
+		return nil, err
+	}
+	authzReq, err := s.validateAuthorizeRequest(oauthClient, redirectUri, req, signed)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate request_uri: %w", err)
+	}
+	ttl := time.Duration(s.cfg.PARTimeLife) * time.Second
+	if err := s.repo.Redis().SavePushedAuthorizationRequest(ctx, id, authzReq, ttl); err != nil {
+		return nil, err
+	}
+	return &uModels.PushedAuthorizationResponse{
+		RequestUri: parRequestUriPrefix + id,
+		ExpiresIn:  int64(ttl.Seconds()),
+	}, nil
+}
+
+// consumePushedAuthorizationRequest đổi request_uri lấy request đã push, request_uri chỉ
+// dùng được một lần và chỉ bởi client đã push nó
+func (s *OAuthServerImpl) consumePushedAuthorizationRequest(ctx context.Context, client *models.OAuthClient, requestUri string) (*models.AuthorizationRequest, error) {
+	id, ok := strings.CutPrefix(requestUri, parRequestUriPrefix)
+	if !ok || id == "" {
+		return nil, uModels.ErrInvalidRequestUri
+	}
+	authzReq, err := s.repo.Redis().ConsumePushedAuthorizationRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if authzReq == nil || authzReq.ClientId != client.ClientId {
+		return nil, uModels.ErrInvalidRequestUri
+	}
+	return authzReq, nil
+}
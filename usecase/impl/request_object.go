@@ -0,0 +1,120 @@
+package impl
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnquangdev/oauth2/repository/models"
+	"github.com/johnquangdev/oauth2/service/oidc"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+)
+
+const (
+	requestObjectLeeway = time.Minute
+	// request object sống lâu thì dễ bị dùng lại, giới hạn như FAPI
+	requestObjectMaxTimeLife = time.Hour
+)
+
+// requestObjectSigningAlgs là thuật toán ký request object được chấp nhận, không có "none" và HMAC
+var requestObjectSigningAlgs = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// applyRequestObject verify request object (RFC 9101) bằng JWKS đã đăng ký của client và lấy
+// mọi tham số authorization từ claims, tham số gửi ngoài request object bị bỏ qua
+func (s *OAuthServerImpl) applyRequestObject(client *models.OAuthClient, req uModels.AuthorizeRequest) (uModels.AuthorizeRequest, error) {
+	invalid := func(format string, args ...interface{}) (uModels.AuthorizeRequest, error) {
+		return req, fmt.Errorf("%w: %s", uModels.ErrInvalidRequestObject, fmt.Sprintf(format, args...))
+	}
+	if client.Jwks == "" {
+		return invalid("client has no registered keys")
+	}
+	var set oidc.JSONWebKeySet
+	if err := json.Unmarshal([]byte(client.Jwks), &set); err != nil {
+		return invalid("client keys are malformed")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(req.Request, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		for _, jwk := range set.Keys {
+			if jwk.Use != "" && jwk.Use != "sig" {
+				continue
+			}
+			// không có kid thì chỉ hợp lệ khi client có đúng một key
+			if jwk.Kid == kid || (kid == "" && len(set.Keys) == 1) {
+				return oidc.ParsePublicKey(jwk)
+			}
+		}
+		return nil, fmt.Errorf("signing key %q not found in client jwks", kid)
+	},
+		jwt.WithValidMethods(requestObjectSigningAlgs),
+		jwt.WithIssuer(client.ClientId),
+		jwt.WithAudience(s.cfg.JWTIssuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(requestObjectLeeway),
+	)
+	if err != nil {
+		return invalid("%v", err)
+	}
+	exp, _ := claims.GetExpirationTime()
+	if time.Until(exp.Time) > requestObjectMaxTimeLife {
+		return invalid("exp is too far in the future")
+	}
+	claim := func(name string) string {
+		value, _ := claims[name].(string)
+		return value
+	}
+	if clientId, ok := claims["client_id"]; ok && clientId != client.ClientId {
+		return invalid("client_id does not match")
+	}
+	if _, ok := claims["request"]; ok {
+		return invalid("request object must not contain request")
+	}
+	if _, ok := claims["request_uri"]; ok {
+		return invalid("request object must not contain request_uri")
+	}
+	return uModels.AuthorizeRequest{
+		ResponseType:        claim("response_type"),
+		ClientId:            client.ClientId,
+		RedirectUri:         claim("redirect_uri"),
+		Scope:               claim("scope"),
+		State:               claim("state"),
+		Nonce:               claim("nonce"),
+		CodeChallenge:       claim("code_challenge"),
+		CodeChallengeMethod: claim("code_challenge_method"),
+		Prompt:              claim("prompt"),
+		SSOSessionId:        req.SSOSessionId,
+		Client:              req.Client,
+	}, nil
+}
+
+// normalizeClientJwks kiểm tra JWK Set client đăng ký và chỉ giữ lại phần public của key
+func normalizeClientJwks(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var set oidc.JSONWebKeySet
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return "", fmt.Errorf("%w: jwks must be a JWK Set", uModels.ErrInvalidClientMetadata)
+	}
+	if len(set.Keys) == 0 {
+		return "", fmt.Errorf("%w: jwks has no keys", uModels.ErrInvalidClientMetadata)
+	}
+	for _, jwk := range set.Keys {
+		if _, err := oidc.ParsePublicKey(jwk); err != nil {
+			return "", fmt.Errorf("%w: jwks key %q: %v", uModels.ErrInvalidClientMetadata, jwk.Kid, err)
+		}
+	}
+	normalized, err := json.Marshal(set)
+	if err != nil {
+		return "", err
+	}
+	return string(normalized), nil
+}
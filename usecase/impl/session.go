@@ -0,0 +1,115 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"gorm.io/gorm"
+)
+
+// revokeSessions là nơi duy nhất thu hồi session: refresh token bị vô hiệu trong DB,
+// access token còn hạn của session bị từ chối ngay qua redis và client được báo qua back-channel logout
+func revokeSessions(ctx context.Context, repo rInterfaces.Repo, cfg utils.Config, sessionIds ...uuid.UUID) error {
+	ttl := time.Duration(cfg.AccessTokenTimeLife) * time.Minute
+	for _, sessionId := range sessionIds {
+		if err := repo.Auth().RevokeSession(ctx, sessionId); err != nil {
+			return err
+		}
+		if err := repo.Redis().RevokeSessionTokens(ctx, sessionId, ttl); err != nil {
+			return err
+		}
+		if err := enqueueBackchannelLogout(ctx, repo, sessionId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeUserSessions thu hồi mọi session của user (đăng xuất khỏi mọi thiết bị).
+// Mốc tokens_valid_after chặn cả những token không gắn với session nào.
+func revokeUserSessions(ctx context.Context, repo rInterfaces.Repo, cfg utils.Config, userId uuid.UUID) error {
+	ttl := time.Duration(cfg.RefreshTokenTimeLife) * time.Hour
+	if err := repo.Redis().SetTokensValidAfter(ctx, userId, time.Now(), ttl); err != nil {
+		return err
+	}
+	sessionIds, err := repo.Auth().RevokeSessionsByUserId(ctx, userId)
+	if err != nil {
+		return err
+	}
+	return revokeSessions(ctx, repo, cfg, sessionIds...)
+}
+
+// blacklistToken thu hồi một token theo jti cho đến khi token tự hết hạn
+func blacklistToken(ctx context.Context, repo rInterfaces.Repo, jti string, expiresAt *jwt.NumericDate) error {
+	if jti == "" || expiresAt == nil {
+		return nil
+	}
+	return repo.Redis().AddBlacklist(ctx, jti, time.Until(expiresAt.Time))
+}
+
+// isTokenRevoked kiểm tra jti trong blacklist và mốc "đăng xuất khỏi mọi thiết bị" của user
+func isTokenRevoked(ctx context.Context, repo rInterfaces.Repo, userId uuid.UUID, jti string, issuedAt *jwt.NumericDate) (bool, error) {
+	if jti != "" {
+		blacklisted, err := repo.Redis().IsTokenBlacklisted(ctx, jti)
+		if err != nil || blacklisted {
+			return blacklisted, err
+		}
+	}
+	validAfter, err := repo.Redis().GetTokensValidAfter(ctx, userId)
+	if err != nil || validAfter == nil {
+		return false, err
+	}
+	// iat và mốc đều tính theo giây: token cấp cùng giây với mốc cũng bị thu hồi
+	return issuedAt == nil || !issuedAt.Time.After(*validAfter), nil
+}
+
+func toSession(session models.Session, currentSessionId uuid.UUID) uModels.Session {
+	return uModels.Session{
+		Id:         session.Id,
+		Device:     session.Device,
+		IPAddress:  session.IPAddress,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.RefreshTokenExpiresAt,
+		Current:    session.Id == currentSessionId,
+	}
+}
+
+func (u AuthImpl) ListSessions(ctx context.Context, userId uuid.UUID, currentSessionId uuid.UUID) ([]uModels.Session, error) {
+	sessions, err := u.repo.Auth().ListActiveSessionsByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]uModels.Session, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, toSession(session, currentSessionId))
+	}
+	return result, nil
+}
+
+func (u AuthImpl) RevokeSession(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) error {
+	session, err := u.repo.Auth().GetSessionById(ctx, sessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uModels.ErrSessionNotFound
+		}
+		return err
+	}
+	// không để lộ session của user khác
+	if session.UserId != userId || session.RevokedAt != nil {
+		return uModels.ErrSessionNotFound
+	}
+	return revokeSessions(ctx, u.repo, u.cfg, session.Id)
+}
+
+func (u AuthImpl) RevokeAllSessions(ctx context.Context, userId uuid.UUID) error {
+	return revokeUserSessions(ctx, u.repo, u.cfg, userId)
+}
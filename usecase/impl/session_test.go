@@ -0,0 +1,66 @@
+package impl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/johnquangdev/oauth2/middleware"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/labstack/echo/v4"
+)
+
+func TestIsTokenRevokedByValidAfter(t *testing.T) {
+	// mốc lưu trong redis chỉ có phần giây
+	validAfter := time.Unix(time.Now().Unix(), 0)
+	tests := []struct {
+		name     string
+		issuedAt *jwt.NumericDate
+		want     bool
+	}{
+		{name: "issued before", issuedAt: jwt.NewNumericDate(validAfter.Add(-time.Second)), want: true},
+		{name: "issued in the same second", issuedAt: jwt.NewNumericDate(validAfter.Add(900 * time.Millisecond)), want: true},
+		{name: "issued after", issuedAt: jwt.NewNumericDate(validAfter.Add(time.Second))},
+		{name: "no iat", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := fakeRepo{redis: &fakeRedis{validAfter: &validAfter}}
+			got, err := isTokenRevoked(context.Background(), repo, uuid.New(), "", tt.issuedAt)
+			if err != nil || got != tt.want {
+				t.Fatalf("isTokenRevoked() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRejectsTokenIssuedInSignOutSecond(t *testing.T) {
+	cfg := testConfig(t)
+	user := &models.User{Id: uuid.New(), Status: uModels.StatusActive, Role: models.RoleUser}
+	tokens, err := newTokenPair(cfg, user, &models.Session{Id: uuid.New()}, tokenLifetime{access: time.Minute, refresh: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "đăng xuất khỏi mọi thiết bị" ghi mốc ngay sau khi token được cấp, trong cùng giây
+	validAfter := time.Unix(time.Now().Unix(), 0)
+	repo := fakeRepo{
+		auth:  &fakeAuth{users: map[uuid.UUID]*models.User{user.Id: user}},
+		redis: &fakeRedis{validAfter: &validAfter},
+	}
+	e := echo.New()
+	m := middleware.NewMiddleware(cfg, repo)
+	e.GET("/v1/auth/profile", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, m.JWTAuthMiddleware())
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/auth/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.accessToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,214 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"gorm.io/gorm"
+)
+
+// tokenPair là access/refresh token phát hành cho một session
+type tokenPair struct {
+	accessToken      string
+	refreshToken     string
+	accessExpiresAt  time.Time
+	refreshExpiresAt time.Time
+}
+
+func (p *tokenPair) toTokenJwt() *uModels.TokenJwt {
+	return &uModels.TokenJwt{
+		AccessToken:           p.accessToken,
+		RefreshToken:          p.refreshToken,
+		AccessTokenExpiresAt:  time.Until(p.accessExpiresAt),
+		RefreshTokenExpiresAt: time.Until(p.refreshExpiresAt),
+	}
+}
+
+// tokenLifetime là thời hạn access/refresh token phát hành cho một session
+type tokenLifetime struct {
+	access  time.Duration
+	refresh time.Duration
+}
+
+func defaultTokenLifetime(cfg utils.Config) tokenLifetime {
+	return tokenLifetime{
+		access:  time.Duration(cfg.AccessTokenTimeLife) * time.Minute,
+		refresh: time.Duration(cfg.RefreshTokenTimeLife) * time.Hour,
+	}
+}
+
+// clientTokenLifetime áp thời hạn riêng của client (phút / giờ), 0 là dùng mặc định của server
+func clientTokenLifetime(cfg utils.Config, accessTokenTimeLife uint16, refreshTokenTimeLife uint16) tokenLifetime {
+	lifetime := defaultTokenLifetime(cfg)
+	if accessTokenTimeLife != 0 {
+		lifetime.access = time.Duration(accessTokenTimeLife) * time.Minute
+	}
+	if refreshTokenTimeLife != 0 {
+		lifetime.refresh = time.Duration(refreshTokenTimeLife) * time.Hour
+	}
+	return lifetime
+}
+
+// newTokenPair tạo access + refresh token cho session.
+// Session của OAuth client có thêm client_id, scope và aud chỉ là client_id,
+// để token của client không dùng được ở các API first-party (/v1/auth, /v1/admin).
+func newTokenPair(cfg utils.Config, user *models.User, session *models.Session, lifetime tokenLifetime) (*tokenPair, error) {
+	var audience []string
+	if session.ClientId != "" {
+		audience = []string{session.ClientId}
+	}
+	accessToken, claimsAccess, err := utils.GenerateToken(cfg, utils.TokenParams{
+		Type:      utils.TokenTypeAccess,
+		UserId:    user.Id,
+		Name:      user.Name,
+		Email:     user.Email,
+		SessionId: session.Id,
+		ClientId:  session.ClientId,
+		Scope:     session.Scope,
+		Audience:  audience,
+		TimeLife:  lifetime.access,
+	})
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, claimsRefresh, err := utils.GenerateToken(cfg, utils.TokenParams{
+		Type:      utils.TokenTypeRefresh,
+		UserId:    user.Id,
+		SessionId: session.Id,
+		ClientId:  session.ClientId,
+		Scope:     session.Scope,
+		Audience:  audience,
+		TimeLife:  lifetime.refresh,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tokenPair{
+		accessToken:      accessToken,
+		refreshToken:     refreshToken,
+		accessExpiresAt:  claimsAccess.ExpiresAt.Time,
+		refreshExpiresAt: claimsRefresh.ExpiresAt.Time,
+	}, nil
+}
+
+// createSession tạo session mới cho user và phát hành cặp token đầu tiên
+func createSession(ctx context.Context, repo rInterfaces.Repo, cfg utils.Config, user *models.User, session *models.Session, lifetime tokenLifetime) (*tokenPair, error) {
+	if session.Id == uuid.Nil {
+		session.Id = uuid.New()
+	}
+	session.UserId = user.Id
+	tokens, err := newTokenPair(cfg, user, session, lifetime)
+	if err != nil {
+		return nil, err
+	}
+	session.RefreshToken = tokens.refreshToken
+	session.RefreshTokenExpiresAt = tokens.refreshExpiresAt
+	if err := repo.Auth().CreateSession(session); err != nil {
+		return nil, fmt.Errorf("create session error: %w", err)
+	}
+	return tokens, nil
+}
+
+// refreshSession đổi refresh token lấy cặp token mới và rotate refresh token trong session.
+// clientId phải khớp với client của session (rỗng với first-party login).
+// Nếu refresh token đã bị rotate mà vẫn được dùng lại thì revoke toàn bộ session.
+func refreshSession(ctx context.Context, repo rInterfaces.Repo, cfg utils.Config, refreshToken string, clientId string) (*tokenPair, *models.Session, error) {
+	audience := cfg.JWTAudience
+	if clientId != "" {
+		audience = clientId
+	}
+	claims, err := utils.VerifyTokenForAudience(refreshToken, cfg, utils.TokenTypeRefresh, audience)
+	if err != nil {
+		return nil, nil, uModels.ErrInvalidRefreshToken
+	}
+	if claims.SessionId == uuid.Nil {
+		return nil, nil, uModels.ErrInvalidRefreshToken
+	}
+
+	// get session from db
+	session, err := repo.Auth().GetSessionById(ctx, claims.SessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, uModels.ErrInvalidRefreshToken
+		}
+		return nil, nil, fmt.Errorf("get session error: %w", err)
+	}
+	if session.UserId != claims.Id || session.ClientId != clientId {
+		return nil, nil, uModels.ErrInvalidRefreshToken
+	}
+	if session.RevokedAt != nil {
+		return nil, nil, uModels.ErrSessionRevoked
+	}
+	revoked, err := isTokenRevoked(ctx, repo, claims.Id, claims.ID, claims.IssuedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, uModels.ErrSessionRevoked
+	}
+	if session.IsBlocked {
+		return nil, nil, uModels.ErrSessionBlocked
+	}
+
+	// token hợp lệ nhưng không còn là refresh token hiện tại => đã bị rotate, có thể bị đánh cắp
+	if session.RefreshToken != refreshToken {
+		if err := revokeSessions(ctx, repo, cfg, session.Id); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, uModels.ErrRefreshTokenReused
+	}
+	if session.RefreshTokenExpiresAt.Before(time.Now()) {
+		return nil, nil, uModels.ErrInvalidRefreshToken
+	}
+
+	// check user status
+	user, err := repo.Auth().GetUserByUserId(ctx, session.UserId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get user error: %w", err)
+	}
+	if user.Status == uModels.StatusBlocked || user.Status == uModels.StatusBanned {
+		return nil, nil, uModels.ErrUserNotActive
+	}
+
+	// session của OAuth client: client bị vô hiệu hoá thì không refresh được, thời hạn token theo client
+	lifetime := defaultTokenLifetime(cfg)
+	if session.ClientId != "" {
+		client, err := repo.Client().GetClientByClientId(ctx, session.ClientId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil, uModels.ErrInvalidRefreshToken
+			}
+			return nil, nil, err
+		}
+		if client.DisabledAt != nil {
+			return nil, nil, uModels.ErrInvalidRefreshToken
+		}
+		lifetime = clientTokenLifetime(cfg, client.AccessTokenTimeLife, client.RefreshTokenTimeLife)
+	}
+
+	// create new JWT (access + refresh token)
+	tokens, err := newTokenPair(cfg, user, session, lifetime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// rotate refresh token, nếu request khác đã rotate trước thì xem như reuse
+	rotated, err := repo.Auth().RotateRefreshToken(ctx, session.Id, refreshToken, tokens.refreshToken, tokens.refreshExpiresAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rotated {
+		if err := revokeSessions(ctx, repo, cfg, session.Id); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, uModels.ErrRefreshTokenReused
+	}
+	return tokens, session, nil
+}
@@ -0,0 +1,182 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+)
+
+// TokenTypeAccessToken là token type URI duy nhất được nhận và phát hành ở token exchange
+const TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+// exchangeToken là grant token exchange (RFC 8693): đổi access token của user lấy token cho đúng
+// một audience client được phép, scope và thời hạn không vượt token gốc. Claim act ghi lại
+// client (hoặc actor_token) đang dùng token thay user, nối tiếp act của token gốc.
+func (s *OAuthServerImpl) exchangeToken(ctx context.Context, client *uModels.Client, req uModels.TokenRequest) (*uModels.TokenResponse, error) {
+	if client.IsPublic {
+		return nil, uModels.NewOAuthError("unauthorized_client", "public clients cannot use token exchange")
+	}
+	if req.SubjectToken == "" || req.SubjectTokenType == "" {
+		return nil, uModels.NewOAuthError("invalid_request", "subject_token and subject_token_type are required")
+	}
+	if req.SubjectTokenType != TokenTypeAccessToken {
+		return nil, uModels.NewOAuthError("invalid_request", "subject_token_type must be "+TokenTypeAccessToken)
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != TokenTypeAccessToken {
+		return nil, uModels.NewOAuthError("invalid_request", "requested_token_type must be "+TokenTypeAccessToken)
+	}
+	if len(req.Resource) > 0 {
+		return nil, uModels.NewOAuthError("invalid_target", "resource is not supported, use audience")
+	}
+	if len(req.Audience) != 1 {
+		return nil, uModels.NewOAuthError("invalid_target", "exactly one audience is required")
+	}
+	audience := req.Audience[0]
+	if !slices.Contains(client.TokenExchangeAudiences, audience) {
+		return nil, uModels.NewOAuthError("invalid_target", fmt.Sprintf("client is not allowed to exchange tokens for audience %q", audience))
+	}
+
+	subject, err := s.exchangeableToken(ctx, req.SubjectToken)
+	if err != nil {
+		return nil, err
+	}
+	if subject == nil {
+		return nil, uModels.NewOAuthError("invalid_request", "subject_token is invalid, expired or revoked")
+	}
+	// token của client (client_credentials) không đại diện cho user nào
+	if subject.IsClient() {
+		return nil, uModels.NewOAuthError("invalid_request", "subject_token must be issued to a user")
+	}
+
+	// không có actor_token thì bên dùng token là chính client đang đổi
+	actor := client.ClientId
+	if req.ActorToken != "" || req.ActorTokenType != "" {
+		if req.ActorToken == "" || req.ActorTokenType != TokenTypeAccessToken {
+			return nil, uModels.NewOAuthError("invalid_request", "actor_token must be an access token with actor_token_type "+TokenTypeAccessToken)
+		}
+		actorClaims, err := s.exchangeableToken(ctx, req.ActorToken)
+		if err != nil {
+			return nil, err
+		}
+		// actor_token phải được cấp cho chính client đang đổi
+		if actorClaims == nil || actorClaims.ClientId != client.ClientId {
+			return nil, uModels.NewOAuthError("invalid_request", "actor_token is invalid or was not issued to this client")
+		}
+		actor = actorClaims.Subject
+	}
+
+	scope, err := exchangeScope(client, subject.Scope, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+	timeLife := min(
+		time.Duration(s.cfg.TokenExchangeTimeLife)*time.Minute,
+		clientTokenLifetime(s.cfg, client.AccessTokenTimeLife, client.RefreshTokenTimeLife).access,
+		time.Until(subject.ExpiresAt.Time),
+	)
+	if timeLife <= 0 {
+		return nil, uModels.NewOAuthError("invalid_request", "subject_token is invalid, expired or revoked")
+	}
+	// sid giữ nguyên để thu hồi session của user cũng vô hiệu token đã đổi
+	accessToken, claims, err := utils.GenerateToken(s.cfg, utils.TokenParams{
+		Type:      utils.TokenTypeAccess,
+		UserId:    subject.Id,
+		Name:      subject.Name,
+		Email:     subject.Email,
+		SessionId: subject.SessionId,
+		ClientId:  client.ClientId,
+		Scope:     scope,
+		Audience:  []string{audience},
+		Act:       &utils.ActorClaim{Subject: actor, Act: subject.Act},
+		TimeLife:  timeLife,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &uModels.TokenResponse{
+		AccessToken:     accessToken,
+		IssuedTokenType: TokenTypeAccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(time.Until(claims.ExpiresAt.Time).Seconds()),
+		Scope:           scope,
+	}, nil
+}
+
+// exchangeableToken trả về claims của access token còn hiệu lực (chưa thu hồi, session và user
+// còn hoạt động) với bất kỳ audience nào, nil nếu không dùng được
+func (s *OAuthServerImpl) exchangeableToken(ctx context.Context, token string) (*utils.TokenClaims, error) {
+	// Introspect đã kiểm tra chữ ký, thu hồi, session và trạng thái user
+	introspection, err := s.Introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !introspection.Active || introspection.TokenType != "access_token" {
+		return nil, nil
+	}
+	claims, err := utils.VerifyIssuedToken(token, s.cfg, utils.TokenTypeAccess)
+	if err != nil {
+		return nil, nil
+	}
+	return claims, nil
+}
+
+// exchangeScope: token đổi được chỉ có scope nằm trong cả token gốc lẫn scope của client.
+// Token gốc không có scope (token first-party) thì giới hạn bởi scope của client.
+func exchangeScope(client *uModels.Client, subjectScope string, requested string) (string, error) {
+	available := client.Scopes
+	if subjectScope != "" {
+		available = nil
+		for _, scope := range strings.Fields(subjectScope) {
+			if slices.Contains(client.Scopes, scope) {
+				available = append(available, scope)
+			}
+		}
+	}
+	scopes := strings.Fields(requested)
+	for _, scope := range scopes {
+		if !slices.Contains(available, scope) {
+			return "", uModels.NewOAuthError("invalid_scope", fmt.Sprintf("scope %q is not allowed for this exchange", scope))
+		}
+	}
+	if len(scopes) == 0 {
+		scopes = available
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+// validateTokenExchangePolicy: chỉ client confidential được đổi token và phải khai báo
+// audience được phép. Audience của chính server không được dùng vì token đổi được phải hẹp hơn.
+func (s *OAuthServerImpl) validateTokenExchangePolicy(req uModels.CreateClientRequest) error {
+	enabled := slices.Contains(req.GrantTypes, GrantTypeTokenExchange)
+	if enabled && req.IsPublic {
+		return fmt.Errorf("%w: token exchange requires a confidential client", uModels.ErrInvalidClientMetadata)
+	}
+	if enabled && len(req.TokenExchangeAudiences) == 0 {
+		return fmt.Errorf("%w: token exchange requires token_exchange_audiences", uModels.ErrInvalidClientMetadata)
+	}
+	if !enabled && len(req.TokenExchangeAudiences) > 0 {
+		return fmt.Errorf("%w: token_exchange_audiences requires the token exchange grant", uModels.ErrInvalidClientMetadata)
+	}
+	for _, audience := range req.TokenExchangeAudiences {
+		if audience == "" || strings.ContainsFunc(audience, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' }) {
+			return fmt.Errorf("%w: invalid token exchange audience %q", uModels.ErrInvalidClientMetadata, audience)
+		}
+		if audience == s.cfg.JWTAudience {
+			return fmt.Errorf("%w: token exchange audience cannot be %q", uModels.ErrInvalidClientMetadata, audience)
+		}
+	}
+	return nil
+}
+
+// toActor chuyển claim act sang response của introspection
+func toActor(act *utils.ActorClaim) *uModels.Actor {
+	if act == nil {
+		return nil
+	}
+	return &uModels.Actor{Sub: act.Subject, Act: toActor(act.Act)}
+}
@@ -0,0 +1,208 @@
+package impl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/johnquangdev/oauth2/middleware"
+	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/repository/models"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
+	"github.com/johnquangdev/oauth2/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// fakeRepo chỉ cài các method mà test cần, method khác panic vì interface nhúng là nil
+type fakeRepo struct {
+	auth   *fakeAuth
+	client *fakeClient
+	redis  rInterfaces.Redis
+}
+
+func (r fakeRepo) Auth() rInterfaces.Auth     { return r.auth }
+func (r fakeRepo) Client() rInterfaces.Client { return r.client }
+func (r fakeRepo) Redis() rInterfaces.Redis   { return r.redis }
+
+type fakeClient struct {
+	rInterfaces.Client
+	clients  map[string]*models.OAuthClient
+	consents []*models.UserConsent
+}
+
+func (f *fakeClient) GetClientByClientId(_ context.Context, clientId string) (*models.OAuthClient, error) {
+	client, ok := f.clients[clientId]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return client, nil
+}
+
+func (f *fakeClient) GetConsent(_ context.Context, userId uuid.UUID, clientId string) (*models.UserConsent, error) {
+	for _, consent := range f.consents {
+		if consent.UserId == userId && consent.ClientId == clientId {
+			return consent, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeClient) SaveConsent(_ context.Context, consent *models.UserConsent) error {
+	f.consents = append(f.consents, consent)
+	return nil
+}
+
+type fakeAuth struct {
+	rInterfaces.Auth
+	users   map[uuid.UUID]*models.User
+	revoked []uuid.UUID
+}
+
+func (a *fakeAuth) RevokeSession(_ context.Context, sessionId uuid.UUID) error {
+	a.revoked = append(a.revoked, sessionId)
+	return nil
+}
+
+func (a *fakeAuth) GetSessionById(context.Context, uuid.UUID) (*models.Session, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (a *fakeAuth) GetUserByUserId(_ context.Context, id uuid.UUID) (*models.User, error) {
+	user, ok := a.users[id]
+	if !ok {
+		return nil, uModels.ErrUserNotFound
+	}
+	return user, nil
+}
+
+type fakeRedis struct {
+	rInterfaces.Redis
+	codes map[string]*models.AuthorizationCode
+	// used là dấu code đã dùng: "" khi chưa có session, "replayed" hoặc id session
+	used       map[string]string
+	validAfter *time.Time
+}
+
+func (r *fakeRedis) ConsumeAuthorizationCode(_ context.Context, code string, _ time.Duration) (*models.AuthorizationCode, error) {
+	authzCode, ok := r.codes[code]
+	if ok && r.used != nil {
+		r.used[code] = ""
+	}
+	delete(r.codes, code)
+	return authzCode, nil
+}
+
+func (r *fakeRedis) ReplayAuthorizationCode(_ context.Context, code string) (uuid.UUID, bool, error) {
+	value, ok := r.used[code]
+	if !ok {
+		return uuid.Nil, false, nil
+	}
+	if value == "" {
+		r.used[code] = "replayed"
+	}
+	sessionId, _ := uuid.Parse(value)
+	return sessionId, true, nil
+}
+
+func (*fakeRedis) RevokeSessionTokens(context.Context, uuid.UUID, time.Duration) error { return nil }
+
+func (*fakeRedis) IsTokenBlacklisted(context.Context, string) (bool, error) { return false, nil }
+func (r *fakeRedis) GetTokensValidAfter(context.Context, uuid.UUID) (*time.Time, error) {
+	return r.validAfter, nil
+}
+func (*fakeRedis) IsSessionRevoked(context.Context, uuid.UUID) (bool, error) { return false, nil }
+
+func testConfig(t *testing.T) utils.Config {
+	t.Helper()
+	keys, err := utils.NewJWTKeys("test-secret-test-secret-test-secret", nil, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return utils.Config{
+		JWTKeys:     keys,
+		JWTIssuer:   "http://localhost:8080",
+		JWTAudience: "oauth2-api",
+		JWTLeeway:   30,
+	}
+}
+
+func TestClientSessionTokenRejectedByAdminAPI(t *testing.T) {
+	cfg := testConfig(t)
+	admin := &models.User{Id: uuid.New(), Email: "admin@example.com", Status: uModels.StatusActive, Role: models.RoleAdmin}
+	repo := fakeRepo{
+		auth:  &fakeAuth{users: map[uuid.UUID]*models.User{admin.Id: admin}},
+		redis: &fakeRedis{},
+	}
+
+	e := echo.New()
+	m := middleware.NewMiddleware(cfg, repo)
+	g := e.Group("/v1/admin", m.JWTAuthMiddleware(), m.RequireAdmin())
+	g.POST("/users/:id/block", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	lifetime := tokenLifetime{access: time.Minute, refresh: time.Hour}
+	firstParty, err := newTokenPair(cfg, admin, &models.Session{Id: uuid.New()}, lifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientSession, err := newTokenPair(cfg, admin, &models.Session{Id: uuid.New(), ClientId: "third-party", Scope: "openid"}, lifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// token cấp trước khi aud chỉ còn client_id vẫn phải bị chặn
+	legacyClient, _, err := utils.GenerateToken(cfg, utils.TokenParams{
+		Type:      utils.TokenTypeAccess,
+		UserId:    admin.Id,
+		SessionId: uuid.New(),
+		ClientId:  "third-party",
+		Scope:     "openid",
+		Audience:  []string{cfg.JWTAudience, "third-party"},
+		TimeLife:  time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  []int
+	}{
+		{name: "first-party admin token", token: firstParty.accessToken, want: []int{http.StatusOK}},
+		{name: "client session token", token: clientSession.accessToken, want: []int{http.StatusUnauthorized, http.StatusForbidden}},
+		{name: "client session token with api audience", token: legacyClient, want: []int{http.StatusUnauthorized, http.StatusForbidden}},
+		{name: "client session refresh token", token: clientSession.refreshToken, want: []int{http.StatusUnauthorized}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/admin/users/"+uuid.NewString()+"/block", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			for _, code := range tt.want {
+				if rec.Code == code {
+					return
+				}
+			}
+			t.Fatalf("status = %d, want one of %v", rec.Code, tt.want)
+		})
+	}
+}
+
+func TestClientSessionRefreshTokenAudience(t *testing.T) {
+	cfg := testConfig(t)
+	user := &models.User{Id: uuid.New(), Status: uModels.StatusActive, Role: models.RoleUser}
+	tokens, err := newTokenPair(cfg, user, &models.Session{Id: uuid.New(), ClientId: "third-party"}, tokenLifetime{access: time.Minute, refresh: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.VerifyToken(tokens.refreshToken, cfg, utils.TokenTypeRefresh); err == nil {
+		t.Fatal("client refresh token must not carry the API audience")
+	}
+	if _, err := utils.VerifyTokenForAudience(tokens.refreshToken, cfg, utils.TokenTypeRefresh, "third-party"); err != nil {
+		t.Fatalf("client refresh token should verify for its client: %v", err)
+	}
+}
@@ -8,28 +8,61 @@ import (
 	uModels "github.com/johnquangdev/oauth2/usecase/models"
 )
 
-type GoogleOauth2 interface {
-	// NewUser(context.Context, rModels.User) error
-	// NewSession(context.Context, rModels.Session) error
-	Login(ctx context.Context, code string) (*uModels.TokenJwt, *uModels.User, error)
-	Logout(context.Context, uuid.UUID) error
-	GetAuthURL() (string, error)
-	// AddBackList(uuid.UUID, string, time.Duration) error
-}
-type GithubOauth2 interface {
-	Login(ctx context.Context, code string) (*uModels.TokenJwt, *uModels.User, error)
-	GetAuthURL() (string, error)
+// OAuth2 là luồng login qua upstream provider, provider chọn theo tên trong registry
+type OAuth2 interface {
+	Login(ctx context.Context, provider string, req uModels.ExchangeTokenRequest) (*uModels.LoginResult, error)
+	GetAuthURL(ctx context.Context, provider string, binding string, authorizationRequestId string) (string, error)
+	GetLinkURL(ctx context.Context, provider string, binding string, userId uuid.UUID) (string, error)
+	ConfirmLink(ctx context.Context, userId uuid.UUID, linkToken string) (*uModels.Identity, error)
+	Providers() []string
 }
 type SystemAuth interface {
-	AddBackList(uuid.UUID, string, time.Duration) error
 	GetUserByProviderAndProviderId(context.Context, string, string) (*uModels.User, error)
 	GetUserById(context.Context, uuid.UUID) (*uModels.User, error)
+	Refresh(ctx context.Context, refreshToken string) (*uModels.TokenJwt, error)
+	ListIdentities(ctx context.Context, userId uuid.UUID) ([]uModels.Identity, error)
+	UnlinkIdentity(ctx context.Context, userId uuid.UUID, identityId uuid.UUID) error
+	Logout(ctx context.Context, refreshToken string) error
+	RevokeToken(ctx context.Context, token string) error
+	ListSessions(ctx context.Context, userId uuid.UUID, currentSessionId uuid.UUID) ([]uModels.Session, error)
+	RevokeSession(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) error
+	RevokeAllSessions(ctx context.Context, userId uuid.UUID) error
+	ListAuthorizedApps(ctx context.Context, userId uuid.UUID) ([]uModels.AuthorizedApp, error)
+	RevokeAuthorizedApp(ctx context.Context, userId uuid.UUID, clientId string) error
+	BlockUser(ctx context.Context, userId uuid.UUID) error
+	UnblockUser(ctx context.Context, userId uuid.UUID) error
+}
+
+// OAuthServer là các endpoint authorization server dành cho OAuth client
+type OAuthServer interface {
+	CreateClient(ctx context.Context, req uModels.CreateClientRequest) (*uModels.ClientCredentials, error)
+	RegisterClient(ctx context.Context, req uModels.ClientRegistrationRequest) (*uModels.ClientRegistrationResponse, error)
+	ListClients(ctx context.Context) ([]uModels.Client, error)
+	GetClient(ctx context.Context, id uuid.UUID) (*uModels.Client, error)
+	RotateClientSecret(ctx context.Context, id uuid.UUID) (*uModels.ClientCredentials, error)
+	DisableClient(ctx context.Context, id uuid.UUID) error
+	EnableClient(ctx context.Context, id uuid.UUID) error
+	AuthenticateClient(ctx context.Context, clientId string, clientSecret string) (*uModels.Client, error)
+	Introspect(ctx context.Context, token string) (*uModels.Introspection, error)
+	Revoke(ctx context.Context, client *uModels.Client, token string, tokenTypeHint string) error
+	Authorize(ctx context.Context, req uModels.AuthorizeRequest) (*uModels.AuthorizeResult, error)
+	PushAuthorizationRequest(ctx context.Context, client *uModels.Client, req uModels.AuthorizeRequest) (*uModels.PushedAuthorizationResponse, error)
+	GetAuthorizationRequest(ctx context.Context, requestId string) (*uModels.AuthorizationRequestInfo, error)
+	GetConsentRequest(ctx context.Context, ssoSessionId string, requestId string) (*uModels.ConsentRequest, error)
+	Consent(ctx context.Context, ssoSessionId string, requestId string, csrfToken string, approve bool, client uModels.ClientInfo) (string, error)
+	Token(ctx context.Context, client *uModels.Client, req uModels.TokenRequest) (*uModels.TokenResponse, error)
+	UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error)
+	DeviceAuthorization(ctx context.Context, client *uModels.Client, scope string) (*uModels.DeviceAuthorizationResponse, error)
+	GetDeviceVerification(ctx context.Context, ssoSessionId string, userCode string) (*uModels.DeviceVerification, error)
+	ApproveDevice(ctx context.Context, ssoSessionId string, userCode string, approve bool, client uModels.ClientInfo) error
+	EndSession(ctx context.Context, req uModels.EndSessionRequest) (*uModels.EndSessionResult, error)
+	WatchBackchannelLogouts(ctx context.Context, interval time.Duration)
+	Metadata() uModels.ServerMetadata
 }
 type AuthImpl struct {
-	GoogleOauth2 GoogleOauth2
-	GithubOauth2 GithubOauth2
-	SystemAuth   SystemAuth
-	//FacebookOauth2() FacebookOauth2
+	OAuth2      OAuth2
+	SystemAuth  SystemAuth
+	OAuthServer OAuthServer
 }
 
 type UseCaseImpl interface {
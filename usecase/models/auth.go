@@ -7,9 +7,9 @@ import (
 )
 
 const (
-	ProviderGoogle = "google"
-	ProviderGitHub = "github"
-	//ProviderFacebook = "facebook"
+	LinkingPolicyNone    = "none"
+	LinkingPolicyAuto    = "auto"
+	LinkingPolicyConfirm = "confirm"
 )
 
 const (
@@ -20,20 +20,59 @@ const (
 )
 
 type User struct {
-	Id         uuid.UUID `json:"id"`
-	Email      string    `json:"email"`
-	Name       string    `json:"name"`
-	Status     string    `json:"status"`
-	Avatar     string    `json:"avatar"`
-	Provider   string    `json:"provider"`
-	ProviderId string    `json:"provider_id"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	Id         uuid.UUID  `json:"id"`
+	Email      string     `json:"email"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Avatar     string     `json:"avatar"`
+	Identities []Identity `json:"identities,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type Identity struct {
+	Id            uuid.UUID `json:"id"`
+	Provider      string    `json:"provider"`
+	ProviderId    string    `json:"provider_id"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// LoginResult là kết quả của callback, tuỳ luồng mà chỉ một phần được set
+type LoginResult struct {
+	Token *TokenJwt `json:"token,omitempty"`
+	User  *User     `json:"userinfo,omitempty"`
+	// LinkedIdentity khác nil khi callback thuộc luồng liên kết thêm provider
+	LinkedIdentity *Identity `json:"linked_identity,omitempty"`
+	// PendingLink khác nil khi email trùng tài khoản có sẵn và cần user xác nhận liên kết
+	PendingLink *PendingLink `json:"pending_link,omitempty"`
+	// AuthorizationRedirect khác rỗng khi login là bước xác thực của /v1/oauth/authorize:
+	// trình duyệt được redirect về client kèm code, SSOSessionId được ghi vào cookie
+	AuthorizationRedirect string `json:"-"`
+	SSOSessionId          string `json:"-"`
+}
+
+type PendingLink struct {
+	LinkToken string        `json:"link_token"`
+	Provider  string        `json:"provider"`
+	Email     string        `json:"email"`
+	ExpiresIn time.Duration `json:"expires_in"`
 }
 
 type ExchangeTokenRequest struct {
 	Code  string `json:"code"`
 	State string `json:"state"`
+	// Binding là giá trị cookie của trình duyệt đã bắt đầu login
+	Binding string `json:"-"`
+	// Client là thông tin request, lưu vào session được tạo
+	Client ClientInfo `json:"-"`
+}
+
+// ClientInfo là metadata của request do delivery truyền xuống
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
 }
 
 type TokenJwt struct {
@@ -42,3 +81,14 @@ type TokenJwt struct {
 	AccessTokenExpiresAt  time.Duration `json:"access_token_expires_at,omitempty"`
 	RefreshTokenExpiresAt time.Duration `json:"refresh_token_expires_at,omitempty"`
 }
+
+type Session struct {
+	Id         uuid.UUID  `json:"id"`
+	Device     string     `json:"device"`
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import "errors"
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, session revoked")
+	ErrSessionRevoked      = errors.New("session has been revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrSessionBlocked      = errors.New("session has been blocked")
+	ErrUserNotActive       = errors.New("user is blocked or banned")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrMissingState        = errors.New("missing oauth state")
+	ErrInvalidState        = errors.New("oauth state is invalid, expired or already used")
+	ErrProviderNotFound    = errors.New("oauth provider not found")
+	ErrIdentityLinked      = errors.New("this provider account is already linked to another user")
+	ErrIdentityNotFound    = errors.New("identity not found")
+	ErrLastIdentity        = errors.New("cannot unlink the last linked identity")
+	ErrInvalidLinkToken    = errors.New("link token is invalid, expired or belongs to another user")
+	ErrInvalidClient       = errors.New("client authentication failed")
+	ErrUnknownClient       = errors.New("unknown client_id")
+	ErrInvalidRedirectUri  = errors.New("redirect_uri is not registered for this client")
+	ErrInvalidAuthzRequest = errors.New("authorization request is invalid or expired")
+	ErrClientNotFound      = errors.New("client not found")
+	ErrPublicClient        = errors.New("public clients have no secret")
+	// ErrInvalidClientMetadata và ErrInvalidClientRedirect được wrap kèm chi tiết, map sang lỗi RFC 7591
+	ErrInvalidClientMetadata = errors.New("invalid client metadata")
+	ErrInvalidClientRedirect = errors.New("invalid redirect uri")
+	ErrInvalidToken          = errors.New("access token is invalid, expired or revoked")
+	ErrInsufficientScope     = errors.New("access token does not have the openid scope")
+	ErrInvalidUserCode       = errors.New("user code is invalid, expired or already used")
+	ErrLoginRequired         = errors.New("login required")
+	ErrAuthorizedAppNotFound = errors.New("authorized app not found")
+	ErrInvalidRequestUri     = errors.New("request_uri is invalid, expired or already used")
+	// ErrInvalidRequestObject được wrap kèm lý do, map sang lỗi invalid_request_object
+	ErrInvalidRequestObject = errors.New("invalid request object")
+	// ErrInvalidLogoutRequest được wrap kèm lý do, /v1/oauth/end_session không redirect khi gặp lỗi này
+	ErrInvalidLogoutRequest = errors.New("invalid logout request")
+)
+
+// OAuthError là lỗi trả về cho client theo RFC 6749 mục 4.1.2.1 và 5.2
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func NewOAuthError(code string, description string) *OAuthError {
+	return &OAuthError{
+		Code:        code,
+		Description: description,
+	}
+}
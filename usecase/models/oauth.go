@@ -0,0 +1,305 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Client struct {
+	Id           uuid.UUID `json:"id"`
+	ClientId     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectUris []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	GrantTypes   []string  `json:"grant_types"`
+	IsPublic     bool      `json:"is_public"`
+	FirstParty   bool      `json:"first_party"`
+	// Jwks là JWK Set công khai để verify request object (RFC 9101)
+	Jwks                               json.RawMessage `json:"jwks,omitempty"`
+	RequirePushedAuthorizationRequests bool            `json:"require_pushed_authorization_requests"`
+	RequireSignedRequestObject         bool            `json:"require_signed_request_object"`
+	// URI cho OIDC logout (RP-Initiated, Back-Channel, Front-Channel Logout 1.0)
+	PostLogoutRedirectUris []string `json:"post_logout_redirect_uris"`
+	BackchannelLogoutUri   string   `json:"backchannel_logout_uri,omitempty"`
+	FrontchannelLogoutUri  string   `json:"frontchannel_logout_uri,omitempty"`
+	// TokenExchangeAudiences là audience client được xin ở grant token exchange (RFC 8693)
+	TokenExchangeAudiences []string `json:"token_exchange_audiences,omitempty"`
+	// thời hạn token riêng (phút / giờ), 0 là dùng mặc định của server
+	AccessTokenTimeLife  uint16     `json:"access_token_time_life"`
+	RefreshTokenTimeLife uint16     `json:"refresh_token_time_life"`
+	DisabledAt           *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+}
+
+// CreateClientRequest là thông tin để đăng ký client mới.
+// GrantTypes rỗng mà có RedirectUris thì mặc định là authorization_code + refresh_token.
+type CreateClientRequest struct {
+	Name         string
+	RedirectUris []string
+	Scopes       []string
+	GrantTypes   []string
+	IsPublic     bool
+	FirstParty   bool
+	Jwks         json.RawMessage
+	// RequirePushedAuthorizationRequests: chỉ nhận authorization request qua PAR (RFC 9126),
+	// RequireSignedRequestObject: chỉ nhận request object đã ký (RFC 9101)
+	RequirePushedAuthorizationRequests bool
+	RequireSignedRequestObject         bool
+	PostLogoutRedirectUris             []string
+	BackchannelLogoutUri               string
+	FrontchannelLogoutUri              string
+	TokenExchangeAudiences             []string
+	AccessTokenTimeLife                uint16
+	RefreshTokenTimeLife               uint16
+}
+
+// ClientRegistrationRequest là client metadata của RFC 7591 mục 2
+type ClientRegistrationRequest struct {
+	ClientName              string   `json:"client_name"`
+	RedirectUris            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	ResponseTypes           []string `json:"response_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	Scope                   string   `json:"scope"`
+	// Jwks và các cờ require_* theo RFC 9101 mục 10.5 và RFC 9126 mục 6
+	Jwks                               json.RawMessage `json:"jwks"`
+	RequirePushedAuthorizationRequests bool            `json:"require_pushed_authorization_requests"`
+	RequireSignedRequestObject         bool            `json:"require_signed_request_object"`
+	// metadata logout của OpenID Connect
+	PostLogoutRedirectUris []string `json:"post_logout_redirect_uris"`
+	BackchannelLogoutUri   string   `json:"backchannel_logout_uri"`
+	FrontchannelLogoutUri  string   `json:"frontchannel_logout_uri"`
+}
+
+// ClientRegistrationResponse theo RFC 7591 mục 3.2.1
+type ClientRegistrationResponse struct {
+	ClientId                           string          `json:"client_id"`
+	ClientSecret                       string          `json:"client_secret,omitempty"`
+	ClientIdIssuedAt                   int64           `json:"client_id_issued_at"`
+	ClientSecretExpiresAt              *int64          `json:"client_secret_expires_at,omitempty"`
+	ClientName                         string          `json:"client_name"`
+	RedirectUris                       []string        `json:"redirect_uris"`
+	GrantTypes                         []string        `json:"grant_types"`
+	ResponseTypes                      []string        `json:"response_types"`
+	TokenEndpointAuthMethod            string          `json:"token_endpoint_auth_method"`
+	Scope                              string          `json:"scope"`
+	Jwks                               json.RawMessage `json:"jwks,omitempty"`
+	RequirePushedAuthorizationRequests bool            `json:"require_pushed_authorization_requests,omitempty"`
+	RequireSignedRequestObject         bool            `json:"require_signed_request_object,omitempty"`
+	PostLogoutRedirectUris             []string        `json:"post_logout_redirect_uris,omitempty"`
+	BackchannelLogoutUri               string          `json:"backchannel_logout_uri,omitempty"`
+	FrontchannelLogoutUri              string          `json:"frontchannel_logout_uri,omitempty"`
+}
+
+// ClientCredentials là client vừa tạo, secret chỉ trả về đúng một lần (rỗng với client public)
+type ClientCredentials struct {
+	Client
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// AuthorizeRequest là tham số của /v1/oauth/authorize (RFC 6749 mục 4.1.1 và RFC 7636)
+// và của /v1/oauth/par (RFC 9126)
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientId            string
+	RedirectUri         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// Prompt: "login" bắt đăng nhập lại, "consent" bắt hỏi lại quyền,
+	// "none" không được hiện trang đăng nhập hay trang đồng ý
+	Prompt string
+	// Request là request object đã ký (RFC 9101), thay thế mọi tham số ở trên trừ ClientId.
+	// RequestUri là request_uri nhận từ /v1/oauth/par.
+	Request    string
+	RequestUri string
+	// SSOSessionId là cookie phiên đăng nhập của trình duyệt ở authorization server
+	SSOSessionId string
+	Client       ClientInfo
+}
+
+// AuthorizeResult: LoginURL khác rỗng khi user cần đăng nhập, ConsentURL khi user cần
+// đồng ý cấp quyền, ngược lại RedirectURL là redirect_uri của client kèm code hoặc lỗi
+type AuthorizeResult struct {
+	RedirectURL string
+	LoginURL    string
+	ConsentURL  string
+}
+
+// AuthorizationRequestInfo là thông tin hiển thị ở trang chọn provider
+type AuthorizationRequestInfo struct {
+	Id         string
+	ClientName string
+	Scopes     []string
+}
+
+// PushedAuthorizationResponse theo RFC 9126 mục 2.2
+type PushedAuthorizationResponse struct {
+	RequestUri string `json:"request_uri"`
+	ExpiresIn  int64  `json:"expires_in"`
+}
+
+// ConsentRequest là thông tin hiển thị ở màn hình đồng ý. LoginURL khác rỗng khi
+// trình duyệt chưa đăng nhập.
+type ConsentRequest struct {
+	RequestId  string
+	ClientName string
+	Scopes     []ConsentScope
+	LoginURL   string
+	// CsrfToken gửi lại trong form đồng ý
+	CsrfToken string
+}
+
+type ConsentScope struct {
+	Name        string
+	Description string
+}
+
+// AuthorizedApp là app bên thứ ba user đã cấp quyền
+type AuthorizedApp struct {
+	ClientId  string    `json:"client_id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	GrantedAt time.Time `json:"granted_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TokenRequest là tham số của /v1/oauth/token
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectUri  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	DeviceCode   string
+	// tham số của token exchange (RFC 8693 mục 2.1)
+	SubjectToken       string
+	SubjectTokenType   string
+	ActorToken         string
+	ActorTokenType     string
+	RequestedTokenType string
+	Audience           []string
+	Resource           []string
+}
+
+// DeviceAuthorizationResponse theo RFC 8628 mục 3.2
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationUri         string `json:"verification_uri"`
+	VerificationUriComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// DeviceVerification là thông tin hiển thị ở trang duyệt device code.
+// LoginURL khác rỗng khi trình duyệt chưa đăng nhập.
+type DeviceVerification struct {
+	UserCode   string
+	ClientName string
+	Scopes     []string
+	LoginURL   string
+}
+
+// TokenResponse theo RFC 6749 mục 5.1
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	// IdToken chỉ có khi scope có openid
+	IdToken string `json:"id_token,omitempty"`
+	// IssuedTokenType chỉ có ở token exchange (RFC 8693 mục 2.2.1)
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+// ServerMetadata là OpenID Provider metadata (OIDC Discovery mục 3), cũng dùng cho RFC 8414
+type ServerMetadata struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint"`
+	UserinfoEndpoint                           string   `json:"userinfo_endpoint"`
+	JwksUri                                    string   `json:"jwks_uri"`
+	RegistrationEndpoint                       string   `json:"registration_endpoint,omitempty"`
+	IntrospectionEndpoint                      string   `json:"introspection_endpoint"`
+	RevocationEndpoint                         string   `json:"revocation_endpoint"`
+	DeviceAuthorizationEndpoint                string   `json:"device_authorization_endpoint"`
+	PushedAuthorizationRequestEndpoint         string   `json:"pushed_authorization_request_endpoint"`
+	RequirePushedAuthorizationRequests         bool     `json:"require_pushed_authorization_requests"`
+	RequestParameterSupported                  bool     `json:"request_parameter_supported"`
+	RequestUriParameterSupported               bool     `json:"request_uri_parameter_supported"`
+	RequestObjectSigningAlgValuesSupported     []string `json:"request_object_signing_alg_values_supported"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	ResponseModesSupported                     []string `json:"response_modes_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	IntrospectionEndpointAuthMethodsSupported  []string `json:"introspection_endpoint_auth_methods_supported"`
+	RevocationEndpointAuthMethodsSupported     []string `json:"revocation_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                            []string `json:"claims_supported"`
+	AcrValuesSupported                         []string `json:"acr_values_supported"`
+	PromptValuesSupported                      []string `json:"prompt_values_supported"`
+	AuthorizationResponseIssParameterSupported bool     `json:"authorization_response_iss_parameter_supported"`
+	EndSessionEndpoint                         string   `json:"end_session_endpoint"`
+	BackchannelLogoutSupported                 bool     `json:"backchannel_logout_supported"`
+	BackchannelLogoutSessionSupported          bool     `json:"backchannel_logout_session_supported"`
+	FrontchannelLogoutSupported                bool     `json:"frontchannel_logout_supported"`
+	FrontchannelLogoutSessionSupported         bool     `json:"frontchannel_logout_session_supported"`
+}
+
+// Introspection là response của RFC 7662, token không hợp lệ chỉ có active=false
+type Introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientId  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+	SessionId string   `json:"sid,omitempty"`
+	// Act là chuỗi uỷ quyền của token đã qua token exchange (RFC 8693 mục 4.1)
+	Act *Actor `json:"act,omitempty"`
+	// SessionExpiresAt là hạn của session (refresh token hiện tại), không phải hạn của token
+	SessionExpiresAt int64 `json:"session_exp,omitempty"`
+}
+
+// EndSessionRequest là tham số của /v1/oauth/end_session (OIDC RP-Initiated Logout 1.0)
+type EndSessionRequest struct {
+	IdTokenHint           string
+	ClientId              string
+	PostLogoutRedirectUri string
+	State                 string
+	SSOSessionId          string
+	// Confirmed: user đã bấm xác nhận đăng xuất ở trang của authorization server
+	Confirmed bool
+}
+
+// EndSessionResult: ConfirmationRequired thì hiện trang hỏi user trước khi đăng xuất.
+// Ngược lại phiên đã kết thúc, trang đăng xuất nhúng FrontchannelLogoutUris bằng iframe
+// rồi chuyển tới RedirectURL (rỗng thì chỉ báo đã đăng xuất).
+type EndSessionResult struct {
+	ConfirmationRequired   bool
+	ClientName             string
+	FrontchannelLogoutUris []string
+	RedirectURL            string
+}
+
+// Actor là claim act: bên đang dùng token thay cho sub, Act lồng bên trong là bên đã dùng trước đó
+type Actor struct {
+	Sub string `json:"sub"`
+	Act *Actor `json:"act,omitempty"`
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"fmt"
+
+	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/service/github"
+	"github.com/johnquangdev/oauth2/service/google"
+	"github.com/johnquangdev/oauth2/service/oidc"
+	"github.com/johnquangdev/oauth2/service/provider"
+	"github.com/johnquangdev/oauth2/utils"
+)
+
+// newProviderRegistry đăng ký các provider đã được cấu hình
+func newProviderRegistry(cfg utils.Config, repo rInterfaces.Repo) (*provider.Registry, error) {
+	registry := provider.NewRegistry()
+
+	if cfg.ClientId_Google != "" {
+		g, err := google.NewGoogleOAuthService(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.Register(g); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.ClientId_GitHub != "" {
+		g, err := github.NewGithubOauth2Service(repo, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.Register(g); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, providerCfg := range cfg.OIDCProviders {
+		p, err := oidc.NewProvider(providerCfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.Register(p); err != nil {
+			return nil, fmt.Errorf("cannot register oidc provider: %w", err)
+		}
+	}
+	return registry, nil
+}
@@ -1,9 +1,13 @@
 package usecase
 
 import (
+	"fmt"
+
 	rInterfaces "github.com/johnquangdev/oauth2/repository/interfaces"
+	"github.com/johnquangdev/oauth2/service/provider"
 	"github.com/johnquangdev/oauth2/usecase/impl"
 	"github.com/johnquangdev/oauth2/usecase/interfaces"
+	uModels "github.com/johnquangdev/oauth2/usecase/models"
 	"github.com/johnquangdev/oauth2/utils"
 	"github.com/redis/go-redis/v9"
 )
@@ -12,23 +16,35 @@ type UseCase struct {
 	redis *redis.Client
 	cfg   utils.Config
 	repo  rInterfaces.Repo
+	// provider giữ cache (discovery, JWKS...) nên registry chỉ khởi tạo một lần
+	providers *provider.Registry
 }
 
 func (u UseCase) Auth() interfaces.AuthImpl {
-	google := impl.NewOAuth2Google(u.cfg, u.repo)
-	github := impl.NewOAuth2Github(u.cfg, u.redis, u.repo)
-	auth := impl.NewSystemAuth(u.repo)
+	oauth2 := impl.NewOAuth2(u.cfg, u.repo, u.providers)
+	auth := impl.NewSystemAuth(u.cfg, u.repo)
+	oauthServer := impl.NewOAuthServer(u.cfg, u.repo)
 	return interfaces.AuthImpl{
-		GoogleOauth2: google,
-		GithubOauth2: github,
-		SystemAuth:   auth,
+		OAuth2:      oauth2,
+		SystemAuth:  auth,
+		OAuthServer: oauthServer,
 	}
 }
 
 func NewUseCase(cfg utils.Config, repo rInterfaces.Repo, redis *redis.Client) (interfaces.UseCaseImpl, error) {
+	switch cfg.AccountLinkingPolicy {
+	case uModels.LinkingPolicyNone, uModels.LinkingPolicyAuto, uModels.LinkingPolicyConfirm:
+	default:
+		return nil, fmt.Errorf("invalid account linking policy %q", cfg.AccountLinkingPolicy)
+	}
+	providers, err := newProviderRegistry(cfg, repo)
+	if err != nil {
+		return nil, err
+	}
 	return &UseCase{
-		redis: redis,
-		repo:  repo,
-		cfg:   cfg,
+		redis:     redis,
+		repo:      repo,
+		cfg:       cfg,
+		providers: providers,
 	}, nil
 }
@@ -1,6 +1,11 @@
 package utils
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
 )
@@ -14,7 +19,22 @@ type Config struct {
 	Password string `envconfig:"DB_PASSWORD"`
 
 	// jwt configuration
-	SecretKey            string `envconfig:"SECRET_KEY"`
+	SecretKey string `envconfig:"SECRET_KEY"`
+	// Private key PEM (RSA, EC hoặc Ed25519) phân cách bằng dấu phẩy: file đầu dùng để ký, các file sau chỉ để verify.
+	// Để trống thì ký HS256 bằng SECRET_KEY.
+	JWTPrivateKeyFiles []string `envconfig:"JWT_PRIVATE_KEY_FILES"`
+	// Keyring cho phép rotate key khi server đang chạy (quản lý bằng CLI -keyring-*),
+	// manifest được đọc lại mỗi JWT_KEYRING_RELOAD_INTERVAL giây
+	JWTKeyringDir            string   `envconfig:"JWT_KEYRING_DIR"`
+	JWTKeyringReloadInterval uint16   `envconfig:"JWT_KEYRING_RELOAD_INTERVAL" default:"30"`
+	JWTKeys                  *JWTKeys `ignored:"true"`
+	// Sau khi chuyển sang key bất đối xứng, token HS256 cũ chỉ còn được chấp nhận tới thời điểm này (RFC 3339).
+	// Để trống là từ chối ngay.
+	JWTLegacyHS256Until time.Time `envconfig:"JWT_LEGACY_HS256_UNTIL"`
+	// iss của token phát hành và aud mặc định (API này), độ lệch đồng hồ cho phép khi verify (giây)
+	JWTIssuer            string `envconfig:"JWT_ISSUER" default:"http://localhost:8080"`
+	JWTAudience          string `envconfig:"JWT_AUDIENCE" default:"oauth2-api"`
+	JWTLeeway            uint16 `envconfig:"JWT_LEEWAY" default:"30"`
 	AccessTokenTimeLife  uint16 `envconfig:"ACCESS_TOKEN_TIME_LIFE"`
 	RefreshTokenTimeLife uint16 `envconfig:"REFRESH_TOKEN_TIME_LIFE"`
 
@@ -23,18 +43,96 @@ type Config struct {
 	RedisPassword string `envconfig:"REDIS_PASSWORD"`
 	RedisPort     string `envconfig:"REDIS_PORT"`
 
+	// Proxy/load balancer tin cậy (IP hoặc CIDR, phân cách bằng dấu phẩy).
+	// Chỉ khi request đi qua các proxy này thì X-Forwarded-For mới được dùng để lấy IP client.
+	TrustedProxies string `envconfig:"TRUSTED_PROXIES"`
+
 	// OAuth2 Google configuration
 	RedirectUrl_Google  string `envconfig:"REDIRECT_URL_GOOGLE"`
 	ClientId_Google     string `envconfig:"CLIENT_ID_GOOGLE"`
 	ClientSecret_Google string `envconfig:"CLIENT_SECRET_GOOGLE"`
 	Scopes_Google       string `envconfig:"SCOPES_GOOGLE"`
 
+	// Authorization server: scope hỗ trợ, hạn của authorization code (giây) và của SSO session (giờ)
+	OAuthScopes               string `envconfig:"OAUTH_SCOPES" default:"openid profile email offline_access"`
+	AuthorizationCodeTimeLife uint16 `envconfig:"AUTHORIZATION_CODE_TIME_LIFE" default:"60"`
+	SSOSessionTimeLife        uint16 `envconfig:"SSO_SESSION_TIME_LIFE" default:"24"`
+	// Device authorization grant (RFC 8628): hạn của device/user code và khoảng cách poll tối thiểu (giây)
+	DeviceCodeTimeLife uint16 `envconfig:"DEVICE_CODE_TIME_LIFE" default:"600"`
+	DeviceCodeInterval uint16 `envconfig:"DEVICE_CODE_INTERVAL" default:"5"`
+	// Pushed authorization requests (RFC 9126): hạn của request_uri (giây), bắt buộc PAR cho mọi client
+	PARTimeLife                        uint16 `envconfig:"PAR_TIME_LIFE" default:"60"`
+	RequirePushedAuthorizationRequests bool   `envconfig:"REQUIRE_PUSHED_AUTHORIZATION_REQUESTS" default:"false"`
+	// OIDC Back-Channel Logout: số lần gửi logout token tối đa và chu kỳ quét hàng đợi (giây)
+	BackchannelLogoutMaxAttempts uint16 `envconfig:"BACKCHANNEL_LOGOUT_MAX_ATTEMPTS" default:"5"`
+	BackchannelLogoutInterval    uint16 `envconfig:"BACKCHANNEL_LOGOUT_INTERVAL" default:"5"`
+	// Cho phép gửi logout token tới địa chỉ nội bộ (loopback, private...), chỉ bật cho môi trường dev
+	// hoặc khi mọi client có backchannel_logout_uri đều do admin tạo
+	BackchannelLogoutAllowPrivateNetworks bool `envconfig:"BACKCHANNEL_LOGOUT_ALLOW_PRIVATE_NETWORKS" default:"false"`
+	// Token exchange (RFC 8693): thời hạn tối đa của token đổi được (phút)
+	TokenExchangeTimeLife uint16 `envconfig:"TOKEN_EXCHANGE_TIME_LIFE" default:"5"`
+
+	// Dynamic client registration (RFC 7591) ở /v1/oauth/register, tắt mặc định.
+	// Nếu có DYNAMIC_REGISTRATION_TOKEN thì request phải gửi token này dạng Bearer (initial access token).
+	DynamicClientRegistration bool   `envconfig:"DYNAMIC_CLIENT_REGISTRATION" default:"false"`
+	DynamicRegistrationToken  string `envconfig:"DYNAMIC_REGISTRATION_TOKEN"`
+
+	// OAuth2 state (chống login CSRF), tính bằng phút
+	OAuthStateTimeLife uint16 `envconfig:"OAUTH_STATE_TIME_LIFE" default:"10"`
+
+	// Liên kết tài khoản theo email đã xác minh: none (mặc định), auto hoặc confirm
+	AccountLinkingPolicy string `envconfig:"ACCOUNT_LINKING_POLICY" default:"none"`
+	// Thời gian chờ user xác nhận liên kết (policy confirm), tính bằng phút
+	AccountLinkTimeLife uint16 `envconfig:"ACCOUNT_LINK_TIME_LIFE" default:"15"`
+
 	RedirectUrl_GitHub  string `envconfig:"REDIRECT_URL_GITHUB"`
 	ClientId_GitHub     string `envconfig:"CLIENT_ID_GITHUB"`
 	ClientSecret_GitHub string `envconfig:"CLIENT_SECRET_GITHUB"`
+
+	// Generic OpenID Connect providers (Keycloak, Okta, Azure AD, GitLab...) đọc từ file JSON
+	OIDCProvidersFile string               `envconfig:"OIDC_PROVIDERS_FILE"`
+	OIDCProviders     []OIDCProviderConfig `ignored:"true"`
+}
+
+type OIDCProviderConfig struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientId     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectUrl  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+	// Claims map claim của IdP sang field của user, để trống thì dùng claim chuẩn của OIDC
+	Claims OIDCClaimMapping `json:"claims"`
+}
+
+type OIDCClaimMapping struct {
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"email_verified"`
+	Name          string `json:"name"`
+	Avatar        string `json:"avatar"`
 }
 
 func LoadConfig() (*Config, error) {
+	cfg, err := LoadEnv()
+	if err != nil {
+		return &Config{}, err
+	}
+	cfg.JWTKeys, err = NewJWTKeys(cfg.SecretKey, cfg.JWTPrivateKeyFiles, cfg.JWTKeyringDir, cfg.JWTLegacyHS256Until)
+	if err != nil {
+		return &Config{}, err
+	}
+	if cfg.OIDCProvidersFile != "" {
+		cfg.OIDCProviders, err = loadOIDCProviders(cfg.OIDCProvidersFile)
+		if err != nil {
+			return &Config{}, err
+		}
+	}
+	return cfg, nil
+}
+
+// LoadEnv chỉ đọc biến môi trường, không nạp key hay file provider (dùng cho các lệnh CLI)
+func LoadEnv() (*Config, error) {
 	err := godotenv.Load()
 	if err != nil {
 		return &Config{}, err
@@ -46,3 +144,16 @@ func LoadConfig() (*Config, error) {
 	}
 	return cfg, nil
 }
+
+// loadOIDCProviders đọc danh sách provider, cho phép dùng ${ENV} để không ghi secret vào file
+func loadOIDCProviders(path string) ([]OIDCProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read oidc providers file: %w", err)
+	}
+	var providers []OIDCProviderConfig
+	if err := json.Unmarshal([]byte(os.ExpandEnv(string(data))), &providers); err != nil {
+		return nil, fmt.Errorf("failed to parse oidc providers file: %w", err)
+	}
+	return providers, nil
+}
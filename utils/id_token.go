@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// IDTokenClaims là claims của OpenID Connect ID token (OIDC Core mục 2)
+type IDTokenClaims struct {
+	Nonce     string           `json:"nonce,omitempty"`
+	AuthTime  *jwt.NumericDate `json:"auth_time,omitempty"`
+	Amr       []string         `json:"amr,omitempty"`
+	Acr       string           `json:"acr,omitempty"`
+	Azp       string           `json:"azp,omitempty"`
+	AtHash    string           `json:"at_hash,omitempty"`
+	SessionId string           `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IDTokenParams là thông tin để phát hành ID token cho client
+type IDTokenParams struct {
+	UserId    uuid.UUID
+	ClientId  string
+	Nonce     string
+	AuthTime  time.Time
+	Amr       []string
+	Acr       string
+	SessionId uuid.UUID
+	// AccessToken phát hành cùng ID token, dùng để tính at_hash
+	AccessToken string
+	TimeLife    time.Duration
+}
+
+// GenerateIDToken tạo ID token với aud là client_id. Token có header typ mặc định (JWT)
+// nên không dùng được thay access token hay refresh token.
+func GenerateIDToken(cfg Config, p IDTokenParams) (string, error) {
+	now := time.Now().UTC()
+	token, err := cfg.JWTKeys.signWith("", func(alg string) jwt.Claims {
+		claims := IDTokenClaims{
+			Nonce:    p.Nonce,
+			AuthTime: jwt.NewNumericDate(p.AuthTime),
+			Amr:      p.Amr,
+			Acr:      p.Acr,
+			Azp:      p.ClientId,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        uuid.NewString(),
+				Issuer:    cfg.JWTIssuer,
+				Subject:   p.UserId.String(),
+				Audience:  jwt.ClaimStrings{p.ClientId},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(p.TimeLife)),
+			},
+		}
+		if p.SessionId != uuid.Nil {
+			claims.SessionId = p.SessionId.String()
+		}
+		if p.AccessToken != "" {
+			claims.AtHash = tokenHash(alg, p.AccessToken)
+		}
+		return claims
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign id token: %w", err)
+	}
+	return token, nil
+}
+
+// tokenHash là nửa trái hash của token theo hàm băm của alg, base64url (OIDC Core mục 3.1.3.6)
+func tokenHash(alg string, token string) string {
+	hash := crypto.SHA256
+	switch {
+	case strings.HasSuffix(alg, "384"):
+		hash = crypto.SHA384
+	case strings.HasSuffix(alg, "512"), alg == "EdDSA":
+		hash = crypto.SHA512
+	}
+	h := hash.New()
+	h.Write([]byte(token))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// VerifyIDTokenHint kiểm tra id_token_hint của /v1/oauth/end_session: chữ ký, iss và header typ
+// của ID token. Token đã hết hạn vẫn được nhận (OIDC RP-Initiated Logout mục 2).
+func VerifyIDTokenHint(cfg Config, tokenStr string) (*IDTokenClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &IDTokenClaims{}, cfg.JWTKeys.Keyfunc,
+		jwt.WithValidMethods(cfg.JWTKeys.Algorithms()),
+		jwt.WithoutClaimsValidation(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token hint: %w", err)
+	}
+	claims, ok := token.Claims.(*IDTokenClaims)
+	if !ok || claims.Issuer != cfg.JWTIssuer || len(claims.Audience) == 0 {
+		return nil, fmt.Errorf("invalid id token hint")
+	}
+	// access token và refresh token có typ riêng, ID token giữ typ mặc định
+	if typ, _ := token.Header["typ"].(string); typ != "" && !strings.EqualFold(typ, "JWT") {
+		return nil, fmt.Errorf("invalid token type %q", typ)
+	}
+	return claims, nil
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// ParseTrustedProxies đọc danh sách proxy tin cậy dạng "10.0.0.0/8,192.168.1.10",
+// IP đơn được hiểu là /32 (hoặc /128 với IPv6)
+func ParseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var ranges []*net.IPNet
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+		}
+		ranges = append(ranges, ipNet)
+	}
+	return ranges, nil
+}
+
+// IsPublicIP: IP không phải loopback, private, link-local, multicast hay unspecified.
+// Dùng trước khi server tự gọi tới URL do client khai báo (chống SSRF).
+func IsPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// PublicOnlyDialControl dùng làm net.Dialer.Control: kiểm tra IP đã resolve ngay lúc kết nối
+// nên DNS rebinding không vượt qua được
+func PublicOnlyDialControl(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("connection to non-public address %s is not allowed", host)
+	}
+	return nil
+}
@@ -9,44 +9,125 @@ import (
 	"github.com/google/uuid"
 )
 
+// Loại token, ghi ở header typ để refresh token không dùng được thay access token và ngược lại
+const (
+	TokenTypeAccess  = "at+jwt"
+	TokenTypeRefresh = "refresh+jwt"
+)
+
 type myCustomClaim struct {
-	Id    uuid.UUID
-	Name  string
-	Gmail string
+	// Id là user id, lấy từ sub khi verify; uuid.Nil với token của client (client_credentials)
+	Id        uuid.UUID `json:"-"`
+	Name      string    `json:"name,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	SessionId uuid.UUID `json:"sid,omitempty"`
+	// ClientId và Scope chỉ có ở token phát hành cho OAuth client
+	ClientId string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// Act chỉ có ở token phát hành qua token exchange (RFC 8693 mục 4.1)
+	Act *ActorClaim `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken tạo JWT với thời gian hết hạn UTC và trả về expires_in (giây)
-func GenerateToken(id uuid.UUID, name string, gmail string, tokenTimeLife time.Duration, cfg string) (string, myCustomClaim) {
-	expiresAt := time.Now().UTC().Add(tokenTimeLife)
+// ActorClaim là claim act: sub là bên đang dùng token, act lồng bên trong là các bên trước đó
+type ActorClaim struct {
+	Subject string      `json:"sub"`
+	Act     *ActorClaim `json:"act,omitempty"`
+}
+
+// TokenClaims là claims của access token và refresh token cho package khác cần giữ lại sau khi verify
+type TokenClaims = myCustomClaim
+
+// IsClient cho biết token được cấp cho chính client (client_credentials) chứ không cho user:
+// sub là client_id thay vì user id
+func (c *myCustomClaim) IsClient() bool {
+	return c.ClientId != "" && c.Subject == c.ClientId
+}
+
+// TokenParams là thông tin để phát hành một token cho user
+type TokenParams struct {
+	Type   string
+	UserId uuid.UUID
+	// Subject để trống thì là UserId, token client_credentials dùng client_id
+	Subject   string
+	Name      string
+	Email     string
+	SessionId uuid.UUID
+	ClientId  string
+	Scope     string
+	// Audience để trống thì dùng JWT_AUDIENCE
+	Audience []string
+	Act      *ActorClaim
+	TimeLife time.Duration
+}
+
+// GenerateToken tạo JWT với sub, iss, aud, iat, nbf, exp (UTC) và jti.
+// sessionId gắn token với row trong bảng sessions để refresh/revoke theo session,
+// jti riêng cho từng token để có thể thu hồi một token mà không ảnh hưởng token khác
+func GenerateToken(cfg Config, p TokenParams) (string, myCustomClaim, error) {
+	audience := p.Audience
+	if len(audience) == 0 {
+		audience = []string{cfg.JWTAudience}
+	}
+	subject := p.Subject
+	if subject == "" {
+		subject = p.UserId.String()
+	}
+	now := time.Now().UTC()
 	claims := myCustomClaim{
-		Id:    id,
-		Name:  name,
-		Gmail: gmail,
+		Id:        p.UserId,
+		Name:      p.Name,
+		Email:     p.Email,
+		SessionId: p.SessionId,
+		ClientId:  p.ClientId,
+		Scope:     p.Scope,
+		Act:       p.Act,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        uuid.NewString(),
+			Issuer:    cfg.JWTIssuer,
+			Subject:   subject,
+			Audience:  audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(p.TimeLife)),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	t, err := token.SignedString([]byte(cfg))
+	t, err := cfg.JWTKeys.Sign(p.Type, claims)
 	if err != nil {
-		return err.Error(), myCustomClaim{}
+		return "", myCustomClaim{}, fmt.Errorf("failed to sign token: %w", err)
 	}
-	return t, claims
+	return t, claims, nil
 }
 
-func VerifyToken(tokenStr string, secretKey string) (*myCustomClaim, error) {
+// VerifyToken kiểm tra chữ ký, loại token, iss, aud (JWT_AUDIENCE) và exp/nbf/iat với độ lệch đồng hồ JWT_LEEWAY
+func VerifyToken(tokenStr string, cfg Config, tokenType string) (*myCustomClaim, error) {
+	return VerifyTokenForAudience(tokenStr, cfg, tokenType, cfg.JWTAudience)
+}
+
+// VerifyTokenForAudience như VerifyToken nhưng aud phải chứa audience, dùng cho token cấp cho OAuth client
+func VerifyTokenForAudience(tokenStr string, cfg Config, tokenType string, audience string) (*myCustomClaim, error) {
+	return verifyToken(tokenStr, cfg, tokenType, jwt.WithAudience(audience))
+}
+
+// VerifyIssuedToken như VerifyToken nhưng nhận mọi aud, kể cả token đã đổi sang audience của
+// service khác qua token exchange. Chỉ dùng ở introspection, revocation và token exchange.
+func VerifyIssuedToken(tokenStr string, cfg Config, tokenType string) (*myCustomClaim, error) {
+	return verifyToken(tokenStr, cfg, tokenType)
+}
+
+func verifyToken(tokenStr string, cfg Config, tokenType string, options ...jwt.ParserOption) (*myCustomClaim, error) {
 	if strings.TrimSpace(tokenStr) == "" {
 		return nil, fmt.Errorf("token is empty")
 	}
-	token, err := jwt.ParseWithClaims(tokenStr, &myCustomClaim{}, func(token *jwt.Token) (interface{}, error) {
-		// Kiểm tra thuật toán
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secretKey), nil
-	})
-
+	// Kiểm tra thuật toán và chọn key theo kid
+	options = append(options,
+		jwt.WithValidMethods(cfg.JWTKeys.Algorithms()),
+		jwt.WithIssuer(cfg.JWTIssuer),
+		jwt.WithLeeway(time.Duration(cfg.JWTLeeway)*time.Second),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	token, err := jwt.ParseWithClaims(tokenStr, &myCustomClaim{}, cfg.JWTKeys.Keyfunc, options...)
 	if err != nil {
 		return nil, fmt.Errorf("invalid token by err: %v", err)
 	}
@@ -55,9 +136,15 @@ func VerifyToken(tokenStr string, secretKey string) (*myCustomClaim, error) {
 	if !ok || !token.Valid {
 		return nil, fmt.Errorf("invalid token by err: %v", err)
 	}
-	// Kiểm tra thời hạn
-	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(time.Now()) {
-		return nil, fmt.Errorf("token expiresAt")
+	if typ, _ := token.Header["typ"].(string); !strings.EqualFold(typ, tokenType) {
+		return nil, fmt.Errorf("invalid token type %q", typ)
+	}
+	if claims.IsClient() {
+		return claims, nil
+	}
+	claims.Id, err = uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token subject")
 	}
 
 	return claims, nil
@@ -0,0 +1,445 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JSONWebKey là public key được công bố qua /.well-known/jwks.json
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// hmacKid là kid của token ký HS256 bằng SECRET_KEY
+const hmacKid = "hs256"
+
+// jwtKey là một key ký/verify token, kid được tính theo RFC 7638 (thumbprint của public key)
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+	jwk     *JSONWebKey
+	// chỉ dùng cho keyring: thời điểm bắt đầu ký và hết hạn verify (zero là không giới hạn)
+	activatesAt time.Time
+	expiresAt   time.Time
+	pending     bool
+}
+
+func (key *jwtKey) canSign(now time.Time) bool {
+	return !key.pending && !now.Before(key.activatesAt) && key.canVerify(now)
+}
+
+func (key *jwtKey) canVerify(now time.Time) bool {
+	return key.expiresAt.IsZero() || now.Before(key.expiresAt)
+}
+
+// JWTKeys giữ các key dùng để ký và verify token.
+// Với key bất đối xứng, resource server chỉ cần JWKS để verify mà không thể tự phát hành token.
+type JWTKeys struct {
+	mu sync.RWMutex
+	// theo thứ tự ưu tiên ký: key đầu tiên còn ký được là key ký
+	keys  []*jwtKey
+	byKid map[string]*jwtKey
+	// secret HS256: chỉ dùng khi không cấu hình key bất đối xứng nào. Khi đã có key bất đối xứng
+	// thì không bao giờ ký HS256, và chỉ verify token HS256 cũ trước legacyHS256Until.
+	secret           []byte
+	asymmetric       bool
+	legacyHS256Until time.Time
+
+	keyringDir     string
+	keyringModTime time.Time
+	keyringLoaded  bool
+}
+
+// NewJWTKeys nạp key theo một trong hai cách:
+//   - privateKeyFiles: các file PEM cố định, file đầu tiên dùng để ký, các file sau chỉ để verify
+//   - keyringDir: keyring có manifest, hỗ trợ rotate key mà không cần restart (xem Reload)
+//
+// Không cấu hình key bất đối xứng thì ký HS256 bằng SECRET_KEY như trước. Đã cấu hình thì token
+// HS256 cũ chỉ còn được chấp nhận trước legacyHS256Until (zero là không chấp nhận).
+func NewJWTKeys(secret string, privateKeyFiles []string, keyringDir string, legacyHS256Until time.Time) (*JWTKeys, error) {
+	if len(privateKeyFiles) > 0 && keyringDir != "" {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILES and JWT_KEYRING_DIR cannot be used together")
+	}
+	k := &JWTKeys{
+		byKid:            make(map[string]*jwtKey),
+		keyringDir:       keyringDir,
+		asymmetric:       len(privateKeyFiles) > 0 || keyringDir != "",
+		legacyHS256Until: legacyHS256Until,
+	}
+	if secret != "" {
+		k.secret = []byte(secret)
+	}
+	for _, path := range privateKeyFiles {
+		key, err := loadJWTKey(path)
+		if err != nil {
+			return nil, err
+		}
+		k.keys = append(k.keys, key)
+		k.byKid[key.kid] = key
+	}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	if k.signingKey(time.Now()) == nil {
+		return nil, fmt.Errorf("no jwt signing key: set SECRET_KEY, JWT_PRIVATE_KEY_FILES or promote a key in JWT_KEYRING_DIR")
+	}
+	return k, nil
+}
+
+// Reload đọc lại manifest của keyring nếu file đã thay đổi, key lỗi làm cả lần reload thất bại
+// và keyring cũ vẫn được giữ nguyên
+func (k *JWTKeys) Reload() error {
+	if k.keyringDir == "" {
+		return nil
+	}
+	info, err := os.Stat(filepath.Join(k.keyringDir, KeyringManifestFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to stat keyring manifest: %w", err)
+	}
+	var modTime time.Time
+	if info != nil {
+		modTime = info.ModTime()
+	}
+	k.mu.RLock()
+	unchanged := k.keyringLoaded && modTime.Equal(k.keyringModTime)
+	k.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	manifest, err := ReadKeyring(k.keyringDir)
+	if err != nil {
+		return err
+	}
+	keys := make([]*jwtKey, 0, len(manifest.Keys))
+	byKid := make(map[string]*jwtKey, len(manifest.Keys))
+	for _, entry := range manifest.Keys {
+		key, err := loadJWTKey(filepath.Join(k.keyringDir, entry.File))
+		if err != nil {
+			return err
+		}
+		if key.kid != entry.Kid {
+			return fmt.Errorf("keyring entry %s does not match key file %s", entry.Kid, entry.File)
+		}
+		if entry.ActivatesAt == nil {
+			key.pending = true
+		} else {
+			key.activatesAt = *entry.ActivatesAt
+		}
+		if entry.ExpiresAt != nil {
+			key.expiresAt = *entry.ExpiresAt
+		}
+		keys = append(keys, key)
+		byKid[key.kid] = key
+	}
+	// key activate gần nhất ký trước, key chưa activate đứng cuối
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].pending != keys[j].pending {
+			return !keys[i].pending
+		}
+		return keys[i].activatesAt.After(keys[j].activatesAt)
+	})
+
+	k.mu.Lock()
+	k.keys = keys
+	k.byKid = byKid
+	k.keyringModTime = modTime
+	k.keyringLoaded = true
+	k.mu.Unlock()
+	return nil
+}
+
+// Watch reload keyring định kỳ cho tới khi ctx bị huỷ, dùng cùng CLI -keyring-* để rotate key khi server đang chạy
+func (k *JWTKeys) Watch(ctx context.Context, interval time.Duration) {
+	if k.keyringDir == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Reload(); err != nil {
+				log.Printf("failed to reload jwt keyring: %v", err)
+			}
+		}
+	}
+}
+
+// signingKey trả về key ký tại thời điểm now. HS256 chỉ được dùng khi không cấu hình key bất đối xứng,
+// keyring chưa có key nào ký được thì trả về nil để việc ký thất bại chứ không hạ xuống HS256.
+func (k *JWTKeys) signingKey(now time.Time) *jwtKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.keys {
+		if key.canSign(now) {
+			return key
+		}
+	}
+	if k.secret != nil && !k.asymmetric {
+		return &jwtKey{
+			kid:     hmacKid,
+			method:  jwt.SigningMethodHS256,
+			private: k.secret,
+			public:  k.secret,
+		}
+	}
+	return nil
+}
+
+func loadJWTKey(path string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt private key: %w", err)
+	}
+	private, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse jwt private key %s: %w", path, err)
+	}
+	return newJWTKey(private)
+}
+
+func newJWTKey(private crypto.Signer) (*jwtKey, error) {
+	method, err := signingMethodFor(private)
+	if err != nil {
+		return nil, err
+	}
+	jwk, err := publicJWK(private.Public(), method.Alg())
+	if err != nil {
+		return nil, err
+	}
+	return &jwtKey{
+		kid:     jwk.Kid,
+		method:  method,
+		private: private,
+		public:  private.Public(),
+		jwk:     jwk,
+	}, nil
+}
+
+// ParsePrivateKeyPEM đọc private key dạng PKCS#8, PKCS#1 (RSA) hoặc SEC 1 (EC)
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+func signingMethodFor(key crypto.Signer) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("rsa key must be at least 2048 bits")
+		}
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported ec curve %s", k.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", key)
+}
+
+// publicJWK chuyển public key thành JWK, kid là thumbprint SHA-256 theo RFC 7638
+func publicJWK(public crypto.PublicKey, alg string) (*JSONWebKey, error) {
+	jwk := &JSONWebKey{
+		Use: "sig",
+		Alg: alg,
+	}
+	// thumbprint chỉ gồm các member bắt buộc, sắp theo thứ tự từ điển
+	var members map[string]string
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		members = map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N}
+	case *ecdsa.PublicKey:
+		ecdhKey, err := k.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("invalid ec public key: %w", err)
+		}
+		// dạng không nén: 0x04 || X || Y, X và Y có cùng độ dài
+		point := ecdhKey.Bytes()[1:]
+		size := len(point) / 2
+		jwk.Kty = "EC"
+		jwk.Crv = k.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(point[:size])
+		jwk.Y = base64.RawURLEncoding.EncodeToString(point[size:])
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X, "y": jwk.Y}
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X}
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", public)
+	}
+	// encoding/json sắp key của map theo thứ tự từ điển và không thêm khoảng trắng
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(canonical)
+	jwk.Kid = base64.RawURLEncoding.EncodeToString(sum[:])
+	return jwk, nil
+}
+
+// Sign ký claims bằng key hiện tại, header gồm kid và typ (bỏ trống thì giữ "JWT")
+func (k *JWTKeys) Sign(typ string, claims jwt.Claims) (string, error) {
+	return k.signWith(typ, func(string) jwt.Claims {
+		return claims
+	})
+}
+
+// signWith ký token với claims tạo theo alg của key đang ký (at_hash của ID token phụ thuộc alg)
+func (k *JWTKeys) signWith(typ string, build func(alg string) jwt.Claims) (string, error) {
+	key := k.signingKey(time.Now())
+	if key == nil {
+		return "", fmt.Errorf("no active jwt signing key")
+	}
+	token := jwt.NewWithClaims(key.method, build(key.method.Alg()))
+	token.Header["kid"] = key.kid
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+	return token.SignedString(key.private)
+}
+
+// Keyfunc chọn key verify theo kid, alg của token phải khớp với key để tránh nhầm thuật toán.
+// Key đã hết hạn trong keyring không còn được chấp nhận.
+func (k *JWTKeys) Keyfunc(token *jwt.Token) (interface{}, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+	// token HS256 (kể cả token cũ không có kid) verify bằng SECRET_KEY
+	if kid == "" || kid == hmacKid {
+		if k.acceptsHS256(time.Now()) && token.Method == jwt.SigningMethodHS256 {
+			return k.secret, nil
+		}
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	key, ok := k.byKid[kid]
+	if !ok || !key.canVerify(time.Now()) {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// acceptsHS256: token HS256 chỉ được verify khi không có key bất đối xứng,
+// hoặc trong thời gian chuyển tiếp JWT_LEGACY_HS256_UNTIL
+func (k *JWTKeys) acceptsHS256(now time.Time) bool {
+	if k.secret == nil {
+		return false
+	}
+	return !k.asymmetric || now.Before(k.legacyHS256Until)
+}
+
+// JWKS trả về public key còn hiệu lực verify, gồm cả key chưa activate để resource server cache trước.
+// Secret HS256 không bao giờ được công bố.
+func (k *JWTKeys) JWKS() JSONWebKeySet {
+	now := time.Now()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	// k.keys đã theo thứ tự ưu tiên nên key đang ký đứng đầu
+	for _, key := range k.keys {
+		if key.jwk != nil && key.canVerify(now) {
+			set.Keys = append(set.Keys, *key.jwk)
+		}
+	}
+	return set
+}
+
+// Algorithms là các alg được chấp nhận khi verify
+func (k *JWTKeys) Algorithms() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var algs []string
+	add := func(alg string) {
+		if !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	for _, key := range k.byKid {
+		add(key.method.Alg())
+	}
+	if k.acceptsHS256(time.Now()) {
+		add(jwt.SigningMethodHS256.Alg())
+	}
+	sort.Strings(algs)
+	return algs
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret-test-secret-test-secret"
+
+// testKeyFile tạo private key PEM trong thư mục tạm và trả về đường dẫn
+func testKeyFile(t *testing.T, dir string, alg string) string {
+	t.Helper()
+	entry, err := GenerateKeyringKey(dir, alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, entry.File)
+}
+
+func signHS256(t *testing.T, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "user-1"})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestJWTKeysKeyfunc(t *testing.T) {
+	dir := t.TempDir()
+	signingFile := testKeyFile(t, dir, "ES256")
+	verifyFile := testKeyFile(t, dir, "EdDSA")
+
+	hmacOnly, err := NewJWTKeys(testSecret, nil, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	asymmetric, err := NewJWTKeys(testSecret, []string{signingFile, verifyFile}, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := NewJWTKeys(testSecret, []string{signingFile}, "", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyOver, err := NewJWTKeys(testSecret, []string{signingFile}, "", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewJWTKeys("", []string{testKeyFile(t, dir, "ES256")}, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retiredFile := testKeyFile(t, dir, "ES256")
+	expired, err := NewJWTKeys("", []string{signingFile, retiredFile}, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retired, err := NewJWTKeys("", []string{retiredFile}, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// key thứ hai trong keyring đã hết hạn verify
+	expired.keys[1].expiresAt = time.Now().Add(-time.Minute)
+
+	sign := func(keys *JWTKeys) func(t *testing.T) string {
+		return func(t *testing.T) string {
+			t.Helper()
+			signed, err := keys.Sign("", jwt.RegisteredClaims{Subject: "user-1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signed
+		}
+	}
+	hs256 := func(kid string) func(t *testing.T) string {
+		return func(t *testing.T) string { return signHS256(t, kid) }
+	}
+
+	tests := []struct {
+		name    string
+		keys    *JWTKeys
+		token   func(t *testing.T) string
+		wantErr bool
+	}{
+		{name: "hs256 only accepts hs256", keys: hmacOnly, token: hs256(hmacKid)},
+		{name: "hs256 only accepts token without kid", keys: hmacOnly, token: hs256("")},
+		{name: "hs256 only rejects asymmetric token", keys: hmacOnly, token: sign(asymmetric), wantErr: true},
+		{name: "asymmetric accepts signing key", keys: asymmetric, token: sign(asymmetric)},
+		{name: "asymmetric accepts verify-only key", keys: asymmetric, token: sign(mustKeys(t, verifyFile))},
+		{name: "asymmetric rejects hs256", keys: asymmetric, token: hs256(hmacKid), wantErr: true},
+		{name: "asymmetric rejects hs256 without kid", keys: asymmetric, token: hs256(""), wantErr: true},
+		{name: "legacy window accepts hs256", keys: legacy, token: hs256(hmacKid)},
+		{name: "legacy window over rejects hs256", keys: legacyOver, token: hs256(hmacKid), wantErr: true},
+		{name: "unknown kid", keys: asymmetric, token: sign(other), wantErr: true},
+		{name: "expired key", keys: expired, token: sign(retired), wantErr: true},
+		{
+			name: "alg does not match key",
+			keys: asymmetric,
+			token: func(t *testing.T) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "user-1"})
+				token.Header["kid"] = asymmetric.keys[0].kid
+				signed, err := token.SignedString([]byte(testSecret))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return signed
+			},
+			wantErr: true,
+		},
+		{
+			name: "alg none",
+			keys: hmacOnly,
+			token: func(t *testing.T) string {
+				signed, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				return signed
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := jwt.Parse(tt.token(t), tt.keys.Keyfunc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Keyfunc() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func mustKeys(t *testing.T, file string) *JWTKeys {
+	t.Helper()
+	keys, err := NewJWTKeys("", []string{file}, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestJWTKeysNeverSignHS256WithAsymmetricKeys(t *testing.T) {
+	dir := t.TempDir()
+	entry, err := GenerateKeyringKey(dir, "ES256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteKeyring(dir, &KeyringManifest{Keys: []KeyringEntry{*entry}}); err != nil {
+		t.Fatal(err)
+	}
+	// keyring chỉ có key chưa activate: không được hạ xuống ký HS256
+	if _, err := NewJWTKeys(testSecret, nil, dir, time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("expected NewJWTKeys to fail without an active asymmetric key")
+	}
+
+	keys := mustKeys(t, filepath.Join(dir, entry.File))
+	keys.secret = []byte(testSecret)
+	keys.keys[0].expiresAt = time.Now().Add(-time.Minute)
+	if _, err := keys.Sign("", jwt.RegisteredClaims{Subject: "user-1"}); err == nil {
+		t.Fatal("expected Sign to fail when no asymmetric key can sign")
+	}
+	for _, alg := range keys.Algorithms() {
+		if alg == jwt.SigningMethodHS256.Alg() {
+			t.Fatal("HS256 must not be accepted without the legacy window")
+		}
+	}
+}
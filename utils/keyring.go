@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// KeyringManifestFile là file mô tả keyring trong JWT_KEYRING_DIR, server đọc lại khi file thay đổi
+const KeyringManifestFile = "keyring.json"
+
+// KeyringEntry là một key trong keyring.
+// ActivatesAt nil: key mới tạo, đã công bố trong JWKS nhưng chưa dùng để ký.
+// Key đã activate mới nhất là key ký, các key cũ hơn chỉ còn để verify cho tới ExpiresAt.
+type KeyringEntry struct {
+	Kid         string     `json:"kid"`
+	Alg         string     `json:"alg"`
+	File        string     `json:"file"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatesAt *time.Time `json:"activates_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+type KeyringManifest struct {
+	Keys []KeyringEntry `json:"keys"`
+}
+
+// ReadKeyring đọc manifest, thư mục chưa có manifest được xem là keyring rỗng
+func ReadKeyring(dir string) (*KeyringManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, KeyringManifestFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &KeyringManifest{}, nil
+		}
+		return nil, fmt.Errorf("failed to read keyring manifest: %w", err)
+	}
+	var manifest KeyringManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// WriteKeyring ghi manifest qua file tạm rồi rename để server không bao giờ đọc phải file ghi dở
+func WriteKeyring(dir string, manifest *KeyringManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keyring manifest: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, KeyringManifestFile+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write keyring manifest: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write keyring manifest: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write keyring manifest: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, KeyringManifestFile)); err != nil {
+		return fmt.Errorf("failed to write keyring manifest: %w", err)
+	}
+	return nil
+}
+
+// GenerateKeyringKey tạo private key mới cho alg (RS256, ES256, ES384, ES512, EdDSA),
+// ghi file PEM (PKCS#8, quyền 0600) vào dir và trả về entry chưa activate
+func GenerateKeyringKey(dir string, alg string) (*KeyringEntry, error) {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 3072)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		private, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ES512":
+		private, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", alg, err)
+	}
+	key, err := newJWTKey(private)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	now := time.Now().UTC()
+	file := fmt.Sprintf("%s-%s.pem", now.Format("20060102150405"), key.kid[:8])
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, file), data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write private key: %w", err)
+	}
+	return &KeyringEntry{
+		Kid:       key.kid,
+		Alg:       key.method.Alg(),
+		File:      file,
+		CreatedAt: now,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// TokenTypeLogout là header typ của logout token (OIDC Back-Channel Logout mục 2.4)
+const TokenTypeLogout = "logout+jwt"
+
+// BackchannelLogoutEvent là khoá trong claim events của logout token
+const BackchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// LogoutTokenClaims là claims của logout token, không bao giờ có nonce
+type LogoutTokenClaims struct {
+	SessionId string                    `json:"sid,omitempty"`
+	Events    map[string]map[string]any `json:"events"`
+	jwt.RegisteredClaims
+}
+
+// LogoutTokenParams là session bị đăng xuất ở client
+type LogoutTokenParams struct {
+	UserId    uuid.UUID
+	ClientId  string
+	SessionId uuid.UUID
+}
+
+// logoutTokenTimeLife: client chỉ cần nhận token ngay lúc được gửi
+const logoutTokenTimeLife = 2 * time.Minute
+
+// GenerateLogoutToken tạo logout token gửi tới backchannel_logout_uri của client,
+// sid trùng với sid trong ID token đã cấp cho session đó
+func GenerateLogoutToken(cfg Config, p LogoutTokenParams) (string, error) {
+	now := time.Now().UTC()
+	token, err := cfg.JWTKeys.Sign(TokenTypeLogout, LogoutTokenClaims{
+		SessionId: p.SessionId.String(),
+		Events:    map[string]map[string]any{BackchannelLogoutEvent: {}},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    cfg.JWTIssuer,
+			Subject:   p.UserId.String(),
+			Audience:  jwt.ClaimStrings{p.ClientId},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(logoutTokenTimeLife)),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign logout token: %w", err)
+	}
+	return token, nil
+}
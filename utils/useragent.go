@@ -0,0 +1,81 @@
+package utils
+
+import "strings"
+
+// UserAgentInfo là thông tin đọc được từ header User-Agent, đủ để hiển thị danh sách phiên đăng nhập
+type UserAgentInfo struct {
+	Browser string
+	OS      string
+	Device  string
+}
+
+// thứ tự quan trọng: Edge/Opera chứa cả "Chrome", Chrome chứa cả "Safari"
+var browserTokens = []struct {
+	token string
+	name  string
+}{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"},
+	{"PostmanRuntime/", "Postman"},
+}
+
+var osTokens = []struct {
+	token string
+	name  string
+}{
+	{"Windows", "Windows"},
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Android", "Android"},
+	{"CrOS", "ChromeOS"},
+	{"Mac OS X", "macOS"},
+	{"Macintosh", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// ParseUserAgent nhận diện trình duyệt, hệ điều hành và loại thiết bị.
+// Không nhận diện được thì để "Unknown", không trả lỗi.
+func ParseUserAgent(ua string) UserAgentInfo {
+	info := UserAgentInfo{
+		Browser: "Unknown",
+		OS:      "Unknown",
+		Device:  "desktop",
+	}
+	if ua == "" {
+		info.Device = "unknown"
+		return info
+	}
+	for _, b := range browserTokens {
+		if strings.Contains(ua, b.token) {
+			info.Browser = b.name
+			break
+		}
+	}
+	for _, o := range osTokens {
+		if strings.Contains(ua, o.token) {
+			info.OS = o.name
+			break
+		}
+	}
+	switch {
+	case strings.Contains(ua, "iPad") || strings.Contains(ua, "Tablet"):
+		info.Device = "tablet"
+	case strings.Contains(ua, "Mobi") || strings.Contains(ua, "iPhone") || strings.Contains(ua, "Android"):
+		info.Device = "mobile"
+	case strings.Contains(ua, "bot") || strings.Contains(ua, "Bot") || info.Browser == "curl" || info.Browser == "Postman":
+		info.Device = "other"
+	}
+	return info
+}
+
+// String trả về dạng "Chrome on Windows (desktop)" để lưu vào cột device của session
+func (i UserAgentInfo) String() string {
+	return i.Browser + " on " + i.OS + " (" + i.Device + ")"
+}